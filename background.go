@@ -0,0 +1,129 @@
+package png2svg
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// ParseHexColor parses a color on the form "#rrggbb" or "#rgb", with an optional "#"
+func ParseHexColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q, must be on the form #rrggbb or #rgb", s)
+	}
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q, must be on the form #rrggbb or #rgb", s)
+	}
+	return color.NRGBA{uint8(n >> 16), uint8(n >> 8), uint8(n), 0xff}, nil
+}
+
+// rgb returns the color as a single number, on the form 0xrrggbb
+func rgb(c color.NRGBA) int {
+	return int(c.R)<<16 | int(c.G)<<8 | int(c.B)
+}
+
+// hasColor checks if the pixel is fully opaque and has the given color
+func (p *Pixel) hasColor(c color.NRGBA) bool {
+	return p.a == 0xff && p.r == int(c.R) && p.g == int(c.G) && p.b == int(c.B)
+}
+
+// DetectBackground finds the background color of the image. If at least three of the corners
+// have the same color, that is the background color. If not, the most common color along the
+// edges of the image is used. Only fully opaque pixels are considered. Returns false if no
+// background color was found, or if at least two of the corners are transparent, since the
+// image then already has a transparent background.
+func (pi *PixelImage) DetectBackground() (color.NRGBA, bool) {
+	if pi.w == 0 || pi.h == 0 {
+		return color.NRGBA{}, false
+	}
+	count := make(map[color.NRGBA]int)
+	add := func(x, y int) {
+		if p := pi.pixels[y*pi.w+x]; p.a == 0xff {
+			count[color.NRGBA{uint8(p.r), uint8(p.g), uint8(p.b), 0xff}]++
+		}
+	}
+
+	transparent := 0
+	for _, corner := range [4][2]int{{0, 0}, {pi.w - 1, 0}, {0, pi.h - 1}, {pi.w - 1, pi.h - 1}} {
+		if pi.pixels[corner[1]*pi.w+corner[0]].a != 0xff {
+			transparent++
+		}
+		add(corner[0], corner[1])
+	}
+	if transparent >= 2 {
+		return color.NRGBA{}, false
+	}
+	for c, n := range count {
+		if n >= 3 {
+			return c, true
+		}
+	}
+
+	// Count the colors of all the pixels along the edges
+	clear(count)
+	for x := 0; x < pi.w; x++ {
+		add(x, 0)
+		add(x, pi.h-1)
+	}
+	for y := 1; y < pi.h-1; y++ {
+		add(0, y)
+		add(pi.w-1, y)
+	}
+	var (
+		background color.NRGBA
+		most       int
+	)
+	for c, n := range count {
+		// Break ties by the color value, so that the result is always the same
+		if n > most || (n == most && rgb(c) < rgb(background)) {
+			background, most = c, n
+		}
+	}
+	return background, most > 0
+}
+
+// RemoveBackground treats all pixels with the given color as transparent, by marking them as
+// covered, so that they are not drawn. Returns the number of pixels that were removed.
+func (pi *PixelImage) RemoveBackground(c color.NRGBA) int {
+	removed := 0
+	for _, p := range pi.pixels {
+		if !p.covered && p.hasColor(c) {
+			p.covered = true
+			removed++
+		}
+	}
+	if pi.verbose {
+		fmt.Printf("Removed %d background pixels\n", removed)
+	}
+	return removed
+}
+
+// DrawBackground draws a single rectangle with the given color, that covers the entire image,
+// and marks all pixels with that color as covered. This must be done before drawing anything else.
+// Returns the number of pixels that were covered. If the image has transparent pixels, nothing is
+// drawn and an error is returned, since the rectangle would fill them.
+func (pi *PixelImage) DrawBackground(c color.NRGBA) (int, error) {
+	for _, p := range pi.pixels {
+		if p.a == 0 {
+			return 0, fmt.Errorf("can not draw a background behind the transparent pixel at (%d, %d)", p.x, p.y)
+		}
+	}
+	fmt.Fprintf(&pi.prelude, "<rect width=\"%d\" height=\"%d\" fill=\"%s\"/>", pi.w, pi.h, pi.fillString(int(c.R), int(c.G), int(c.B)))
+	covered := 0
+	for _, p := range pi.pixels {
+		if !p.covered && p.hasColor(c) {
+			p.covered = true
+			covered++
+		}
+	}
+	if pi.verbose {
+		fmt.Printf("Drew the background, covering %d pixels\n", covered)
+	}
+	return covered, nil
+}
@@ -0,0 +1,115 @@
+package png2svg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestParseHexColor(t *testing.T) {
+	for s, expected := range map[string]color.NRGBA{
+		"#ff00ff": {0xff, 0, 0xff, 0xff},
+		"#abc":    {0xaa, 0xbb, 0xcc, 0xff},
+		"102030":  {0x10, 0x20, 0x30, 0xff},
+	} {
+		if c, err := ParseHexColor(s); err != nil || c != expected {
+			t.Errorf("ParseHexColor(%q) = %v, %v, expected %v", s, c, err, expected)
+		}
+	}
+	for _, s := range []string{"", "#ff00f", "#gggggg", "red"} {
+		if _, err := ParseHexColor(s); err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+}
+
+func TestBackground(t *testing.T) {
+	// A gray image with a red square in the middle, and a red pixel in one corner
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	gray, red := color.NRGBA{0x80, 0x80, 0x80, 0xff}, color.NRGBA{0xff, 0, 0, 0xff}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.Set(x, y, gray)
+		}
+	}
+	for y := 4; y < 12; y++ {
+		for x := 4; x < 12; x++ {
+			img.Set(x, y, red)
+		}
+	}
+	img.Set(15, 15, red)
+
+	pi := NewPixelImage(img, false)
+	background, ok := pi.DetectBackground()
+	if !ok || background != gray {
+		t.Fatalf("Expected the background to be %v, got %v", gray, background)
+	}
+	if n, err := pi.DrawBackground(background); err != nil || n != 16*16-8*8-1 {
+		t.Errorf("Expected %d background pixels to be covered, got %d (%v)", 16*16-8*8-1, n, err)
+	}
+	pi.coverBoxes()
+	svgDocument := pi.Bytes()
+	if !bytes.Contains(svgDocument, []byte(`<rect width="16" height="16" fill="gray"/>`)) {
+		t.Errorf("Expected a single background rectangle in:\n%s", svgDocument)
+	}
+	if n := len(pi.Rects()); n != 2 {
+		t.Errorf("Expected 2 red rectangles on top of the background, got %d", n)
+	}
+
+	pi = NewPixelImage(img, false)
+	pi.RemoveBackground(background)
+	pi.coverBoxes()
+	if bytes.Contains(pi.Bytes(), []byte("gray")) {
+		t.Error("Expected the background to be removed")
+	}
+
+	// The most common color along the edges is used when the corners differ
+	img.Set(0, 0, red)
+	if background, ok := NewPixelImage(img, false).DetectBackground(); !ok || background != gray {
+		t.Errorf("Expected the background to be %v, got %v", gray, background)
+	}
+}
+
+func TestBackgroundTransparentSprite(t *testing.T) {
+	// A red sprite on a transparent background
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	red := color.NRGBA{0xff, 0, 0, 0xff}
+	for y := 2; y < 6; y++ {
+		for x := 2; x < 6; x++ {
+			img.Set(x, y, red)
+		}
+	}
+	pi := NewPixelImage(img, false)
+	if background, ok := pi.DetectBackground(); ok {
+		t.Errorf("Expected no background, got %v", background)
+	}
+
+	// Only one transparent corner, but the rectangle would still fill it
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if x > 0 || y > 0 {
+				img.Set(x, y, red)
+			}
+		}
+	}
+	pi = NewPixelImage(img, false)
+	if _, ok := pi.DetectBackground(); !ok {
+		t.Error("Expected a background when only one corner is transparent")
+	}
+	if _, err := pi.DrawBackground(red); err == nil {
+		t.Error("Expected an error when drawing a background behind transparent pixels")
+	}
+	pi.coverBoxes()
+	svgDocument := pi.Bytes()
+	if bytes.Contains(svgDocument, []byte(`<rect width="8" height="8"`)) {
+		t.Errorf("Expected no background rectangle in:\n%s", svgDocument)
+	}
+	rendered, err := Rasterize(svgDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if differences, err := CompareImages(img, rendered, 0); err != nil || differences != 0 {
+		t.Errorf("Expected no differences, got %d (%v)", differences, err)
+	}
+}
@@ -59,7 +59,7 @@ func (pi *PixelImage) CreateBox(x, y int) *Box {
 func (pi *PixelImage) ExpandLeft(bo *Box) bool {
 	// Loop from box top left (-1,0) to box bot left (-1,0)
 	x := bo.x - 1
-	if x <= 0 {
+	if x < 0 {
 		return false
 	}
 	for y := bo.y; y < (bo.y + bo.h); y++ {
@@ -79,7 +79,7 @@ func (pi *PixelImage) ExpandLeft(bo *Box) bool {
 func (pi *PixelImage) ExpandUp(bo *Box) bool {
 	// Loop from box top left to box top right
 	y := bo.y - 1
-	if y <= 0 {
+	if y < 0 {
 		return false
 	}
 	for x := bo.x; x < (bo.x + bo.w); x++ {
@@ -140,16 +140,14 @@ func (pi *PixelImage) ExpandOnce(bo *Box) bool {
 	return pi.ExpandDown(bo)
 }
 
-// Expand tries to expand the box to the right and downwards, until it can't expand any more.
+// Expand tries to expand the box until it can't expand any more, using the growth strategy
+// that has been set with SetGrowthStrategy. The default is to expand to the right and downwards.
 // Returns true if the box was expanded at least once.
-func (pi *PixelImage) Expand(bo *Box) (expanded bool) {
-	for {
-		if !pi.ExpandOnce(bo) {
-			break
-		}
-		expanded = true
+func (pi *PixelImage) Expand(bo *Box) bool {
+	if pi.growth != nil {
+		return pi.growth.Grow(pi, bo)
 	}
-	return
+	return RightThenDown.Grow(pi, bo)
 }
 
 // singleHex returns a single digit hex number, as a string
@@ -171,9 +169,6 @@ func shortColorString(r, g, b byte) string {
 // if pink is true, the rectangles will be pink
 // if optimizeColors is true, the color strings will be shortened (and quantized)
 func (pi *PixelImage) CoverBox(bo *Box, pink bool, optimizeColors bool) {
-	// Draw the rectangle
-	rect := pi.svgTag.AddRect(bo.x, bo.y, bo.w, bo.h)
-
 	// Generate a fill color string
 	var colorString string
 	if pink {
@@ -185,11 +180,11 @@ func (pi *PixelImage) CoverBox(bo *Box, pink bool, optimizeColors bool) {
 	} else if optimizeColors {
 		colorString = shortColorString(byte(bo.r), byte(bo.g), byte(bo.b))
 	} else {
-		colorString = fmt.Sprintf("#%02x%02x%02x", bo.r, bo.g, bo.b)
+		colorString = hexColorString(bo.r, bo.g, bo.b)
 	}
 
-	// Set the fill color
-	rect.Fill(colorString)
+	// Draw the rectangle, with the fill color
+	pi.addRect(bo.x, bo.y, bo.w, bo.h, colorString)
 
 	// Mark all covered pixels in the PixelImage
 	for y := bo.y; y < (bo.y + bo.h); y++ {
@@ -198,3 +193,15 @@ func (pi *PixelImage) CoverBox(bo *Box, pink bool, optimizeColors bool) {
 		}
 	}
 }
+
+// coverBoxes covers all remaining pixels by creating boxes and expanding them
+// as much as possible, the same way as the png2svg utility does
+func (pi *PixelImage) coverBoxes() {
+	var x, y int
+	for !pi.Done(x, y) {
+		x, y = pi.FirstUncovered(x, y)
+		box := pi.CreateBox(x, y)
+		pi.Expand(box)
+		pi.CoverBox(box, false, pi.colorOptimize)
+	}
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/xyproto/png2svg"
+)
+
+// input is a PNG file that should be converted, together with the directory
+// it was found in, relative to the directory that was given on the command line
+type input struct {
+	filename string
+	dir      string
+}
+
+// result is the outcome of converting a single input file
+type result struct {
+	input         string
+	output        string
+	inputSize     int64
+	outputSize    int64
+	duration      time.Duration
+	skipped       bool
+	err           error
+	inputChecksum string
+	stats         *png2svg.Stats
+}
+
+// collectInputs expands the given arguments to a list of PNG files.
+// Directories are searched recursively for files ending with ".png".
+func collectInputs(args []string) ([]input, error) {
+	var inputs []input
+	for _, arg := range args {
+		fi, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !fi.IsDir() {
+			inputs = append(inputs, input{arg, "."})
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".png") {
+				return nil
+			}
+			rel, err := filepath.Rel(arg, filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+			inputs = append(inputs, input{path, rel})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return inputs, nil
+}
+
+// isTemplate checks if the given output filename contains a placeholder like {name}
+func isTemplate(outputFilename string) bool {
+	return strings.Contains(outputFilename, "{name}") || strings.Contains(outputFilename, "{dir}")
+}
+
+// expandTemplate returns the output filename for the given input.
+// {name} is replaced with the input filename without the directory and extension,
+// {dir} is replaced with the directory of the input, relative to the searched directory.
+func expandTemplate(template string, in input) string {
+	name := strings.TrimSuffix(filepath.Base(in.filename), filepath.Ext(in.filename))
+	s := strings.ReplaceAll(template, "{name}", name)
+	s = strings.ReplaceAll(s, "{dir}", in.dir)
+	return filepath.Clean(s)
+}
+
+// outputTemplate returns an output filename template that can be used for several input files
+func outputTemplate(outputFilename string) (string, error) {
+	if isTemplate(outputFilename) {
+		return outputFilename, nil
+	}
+	if fi, err := os.Stat(outputFilename); (err == nil && fi.IsDir()) || strings.HasSuffix(outputFilename, string(os.PathSeparator)) {
+		return filepath.Join(outputFilename, "{dir}", "{name}.svg"), nil
+	}
+	return "", errors.New("converting several files requires -o to be a directory or a template like out/{name}.svg")
+}
+
+// checkCollisions returns an error if several inputs would be written to the same output file,
+// since the workers would then overwrite each other
+func checkCollisions(inputs []input, template string) error {
+	if template == "-" {
+		return nil
+	}
+	seen := make(map[string]string)
+	for _, in := range inputs {
+		output := expandTemplate(template, in)
+		if other, ok := seen[output]; ok {
+			return fmt.Errorf("both %s and %s would be written to %s", other, in.filename, output)
+		}
+		seen[output] = in.filename
+	}
+	return nil
+}
+
+// checksum returns the SHA-256 sum of the given file, combined with the given settings
+func checksum(filename, settings string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	h.Write([]byte(settings))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readChecksums reads a file with lines on the form "checksum  filename",
+// like the output of sha256sum. A missing file gives an empty map.
+func readChecksums(filename string) (map[string]string, error) {
+	checksums := make(map[string]string)
+	f, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return checksums, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "  ", 2)
+		if len(fields) == 2 {
+			checksums[fields[1]] = fields[0]
+		}
+	}
+	return checksums, scanner.Err()
+}
+
+// writeChecksums writes the given checksums to a file, sorted by filename
+func writeChecksums(filename string, checksums map[string]string) error {
+	filenames := make([]string, 0, len(checksums))
+	for k := range checksums {
+		filenames = append(filenames, k)
+	}
+	sort.Strings(filenames)
+	var sb strings.Builder
+	for _, k := range filenames {
+		sb.WriteString(checksums[k] + "  " + k + "\n")
+	}
+	return os.WriteFile(filename, []byte(sb.String()), 0644)
+}
+
+// settings returns a string with the flags that affects the generated SVG,
+// so that changing them invalidates the stored checksums
+func (c *Config) settings() string {
+	return strings.Join([]string{
+		fmt.Sprintf("c=%v l=%v p=%v n=%d", c.colorPink, c.limit, c.singlePixelRectangles, c.palReduction),
+		fmt.Sprintf("gzip=%v level=%d precompress=%v", c.gzip, c.compressionLevel, c.precompress),
+		fmt.Sprintf("crop=%s rotate=%d flip=%s downscale=%s grid=%v/%d", c.crop, c.rotate, c.flip, c.downscale, c.grid, c.gridTolerance),
+		fmt.Sprintf("scale=%v width=%v height=%v crisp=%v compact=%v format=%s", c.scale, c.width, c.height, c.crispEdges, c.compact, c.format),
+		fmt.Sprintf("transparent=%v/%d alpha=%d binarize=%v matte=%s", c.transparent.Value(), c.imageOptions.TransparentTolerance,
+			c.imageOptions.AlphaThreshold, c.imageOptions.Binarize, c.matte),
+		fmt.Sprintf("encoding=%s background=%s tiles=%s gradients=%v/%d shapes=%v/%v growth=%s", c.encoding, c.background,
+			c.tiles, c.gradients, c.gradientTolerance, c.shapes, c.shapeTolerance, c.growth),
+		fmt.Sprintf("mode=%s trace=%v", c.mode, c.traceOptions),
+		fmt.Sprintf("metadata=%q/%v", []string{c.metadata.Title, c.metadata.Description, c.metadata.Author, c.metadata.License}, c.sourceMetadata),
+		fmt.Sprintf("editable=%v regions=%v profile=%s", c.editable, c.regions, c.profile),
+	}, " ")
+}
+
+// upToDate checks if the output file is newer than the input file
+func upToDate(inputFilename, outputFilename string) bool {
+	ifi, err := os.Stat(inputFilename)
+	if err != nil {
+		return false
+	}
+	ofi, err := os.Stat(outputFilename)
+	if err != nil {
+		return false
+	}
+	return !ofi.ModTime().Before(ifi.ModTime())
+}
+
+// convert converts a single input file, unless it is up to date
+func convert(c Config, in input, template string, checksums map[string]string) result {
+	start := time.Now()
+	res := result{input: in.filename, output: expandTemplate(template, in)}
+	if fi, err := os.Stat(in.filename); err == nil {
+		res.inputSize = fi.Size()
+	}
+	switch c.update {
+	case "mtime":
+		if upToDate(in.filename, res.output) {
+			res.skipped = true
+		}
+	case "hash":
+		sum, err := checksum(in.filename, c.settings())
+		if err != nil {
+			res.err = err
+			return res
+		}
+		res.inputChecksum = sum
+		if _, err := os.Stat(res.output); err == nil && checksums[res.output] == sum {
+			res.skipped = true
+		}
+	}
+	if isTemplate(c.diffFilename) {
+		c.diffFilename = expandTemplate(c.diffFilename, in)
+		if err := os.MkdirAll(filepath.Dir(c.diffFilename), 0755); err != nil {
+			res.err = err
+			return res
+		}
+	}
+	if res.output == "-" {
+		c.inputFilename = in.filename
+		res.err = Run(&c)
+		res.stats = c.conversionStats
+		res.duration = time.Since(start)
+		return res
+	}
+	if !res.skipped {
+		// Nothing is written in a dry run
+		if !c.dryRun {
+			if err := os.MkdirAll(filepath.Dir(res.output), 0755); err != nil {
+				res.err = err
+				return res
+			}
+		}
+		c.inputFilename = in.filename
+		c.outputFilename = res.output
+		res.err = Run(&c)
+		res.stats = c.conversionStats
+	}
+	if fi, err := os.Stat(res.output); err == nil {
+		res.outputSize = fi.Size()
+	}
+	res.duration = time.Since(start)
+	return res
+}
+
+// RunBatch converts all the input files, using several workers
+func RunBatch(c *Config) error {
+	inputs, err := collectInputs(c.inputFilenames)
+	if err != nil {
+		return err
+	}
+	if len(inputs) == 0 {
+		return errors.New("found no PNG files to convert")
+	}
+
+	template := c.outputFilename
+	if len(inputs) > 1 || (len(c.inputFilenames) == 1 && inputs[0].dir != ".") {
+		if template, err = outputTemplate(c.outputFilename); err != nil {
+			return err
+		}
+	} else if template == "-" && c.update != "" {
+		return errors.New("-u requires an output filename")
+	}
+	if len(inputs) > 1 && c.diffFilename != "" && !isTemplate(c.diffFilename) {
+		return errors.New("converting several files with --diff requires a template like diff/{name}.png")
+	}
+	if err := checkCollisions(inputs, template); err != nil {
+		return err
+	}
+	if c.diffFilename != "" {
+		if err := checkCollisions(inputs, c.diffFilename); err != nil {
+			return err
+		}
+	}
+
+	checksums := make(map[string]string)
+	if c.update == "hash" {
+		if checksums, err = readChecksums(c.checksumFilename); err != nil {
+			return err
+		}
+	}
+
+	jobs := c.jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs > len(inputs) {
+		jobs = len(inputs)
+	}
+	// Progress information from several workers would be mixed up
+	single := *c
+	if jobs > 1 {
+		single.verbose = false
+	}
+
+	results := make([]result, len(inputs))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				results[i] = convert(single, inputs[i], template, checksums)
+			}
+		}()
+	}
+	for i := range inputs {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	if len(results) == 1 && results[0].err != nil {
+		return results[0].err
+	}
+
+	failed := 0
+	for _, res := range results {
+		if res.err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "error: %s: %s\n", res.input, res.err)
+			continue
+		}
+		if res.inputChecksum != "" {
+			checksums[res.output] = res.inputChecksum
+		}
+	}
+	if c.update == "hash" && !c.dryRun {
+		if err := writeChecksums(c.checksumFilename, checksums); err != nil {
+			return err
+		}
+	}
+	if c.stats != "" {
+		// The SVG image may be written to stdout
+		w := os.Stdout
+		if template == "-" {
+			w = os.Stderr
+		}
+		if err := printStats(w, results, c.stats); err != nil {
+			return err
+		}
+	} else if !c.dryRun && (len(results) > 1 || (c.verbose && template != "-")) {
+		printSummary(os.Stdout, results)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d conversions failed", failed, len(results))
+	}
+	return nil
+}
+
+// printSummary writes a table with sizes and timings for each converted file
+func printSummary(w io.Writer, results []result) {
+	var (
+		tw                 = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		totalIn, totalOut  int64
+		totalTime          time.Duration
+		converted, skipped int
+		status             string
+	)
+	fmt.Fprintln(tw, "input\toutput\tPNG size\tSVG size\ttime\tstatus")
+	for _, res := range results {
+		switch {
+		case res.err != nil:
+			status = "failed"
+		case res.skipped:
+			status = "up to date"
+			skipped++
+		default:
+			status = "converted"
+			converted++
+		}
+		totalIn += res.inputSize
+		totalOut += res.outputSize
+		totalTime += res.duration
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\n", res.input, res.output, res.inputSize, res.outputSize, res.duration.Round(time.Millisecond), status)
+	}
+	fmt.Fprintf(tw, "total\t%d converted, %d up to date\t%d\t%d\t%s\n", converted, skipped, totalIn, totalOut, totalTime.Round(time.Millisecond))
+	tw.Flush()
+}
+
+// printStats writes the statistics for each converted file, either as text or as one JSON object per line
+func printStats(w io.Writer, results []result, format string) error {
+	for _, res := range results {
+		if res.stats == nil {
+			continue
+		}
+		if format == "text" {
+			fmt.Fprintf(w, "%s: %s\n", res.input, res.stats)
+			continue
+		}
+		data, err := json.Marshal(struct {
+			Input  string `json:"input"`
+			Output string `json:"output"`
+			*png2svg.Stats
+		}{res.input, res.output, res.stats})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\n", data)
+	}
+	return nil
+}
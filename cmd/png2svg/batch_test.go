@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFiles creates files with the given names, relative to dir, that contain their own names
+func writeFiles(t *testing.T, dir string, filenames ...string) {
+	for _, filename := range filenames {
+		path := filepath.Join(dir, filename)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(filename), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCollectInputs(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "icons/a.png", "icons/sub/b.PNG", "icons/notes.txt", "c.png")
+	inputs, err := collectInputs([]string{filepath.Join(dir, "icons"), filepath.Join(dir, "c.png")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []input{
+		{filepath.Join(dir, "icons", "a.png"), "."},
+		{filepath.Join(dir, "icons", "sub", "b.PNG"), "sub"},
+		{filepath.Join(dir, "c.png"), "."},
+	}
+	if len(inputs) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, inputs)
+	}
+	for i := range expected {
+		if inputs[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], inputs[i])
+		}
+	}
+	if _, err := collectInputs([]string{filepath.Join(dir, "missing.png")}); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestExpandTemplate(t *testing.T) {
+	in := input{filepath.Join("icons", "sub", "save.png"), "sub"}
+	for template, expected := range map[string]string{
+		"out/{name}.svg":       filepath.Join("out", "save.svg"),
+		"out/{dir}/{name}.svg": filepath.Join("out", "sub", "save.svg"),
+		"{name}-{name}.svgz":   "save-save.svgz",
+		"fixed.svg":            "fixed.svg",
+	} {
+		if s := expandTemplate(template, in); s != expected {
+			t.Errorf("Expected %q to expand to %s, got %s", template, expected, s)
+		}
+	}
+	if s := expandTemplate("out/{dir}/{name}.svg", input{"save.png", "."}); s != filepath.Join("out", "save.svg") {
+		t.Errorf("Expected the current directory to be left out, got %s", s)
+	}
+}
+
+func TestCheckCollisions(t *testing.T) {
+	inputs := []input{{filepath.Join("a", "icon.png"), "."}, {filepath.Join("b", "icon.png"), "."}}
+	if err := checkCollisions(inputs, filepath.Join("out", "{dir}", "{name}.svg")); err == nil || !strings.Contains(err.Error(), "icon.svg") {
+		t.Errorf("Expected an error about the output file, got %v", err)
+	}
+	inputs[1].dir = "b"
+	if err := checkCollisions(inputs, filepath.Join("out", "{dir}", "{name}.svg")); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if err := checkCollisions(inputs, "-"); err != nil {
+		t.Errorf("Expected no error when writing to stdout, got %v", err)
+	}
+
+	// Two files with the same name are refused before anything is converted
+	dir := t.TempDir()
+	writeFiles(t, dir, "a/icon.png", "b/icon.png")
+	c := Config{
+		inputFilenames: []string{filepath.Join(dir, "a", "icon.png"), filepath.Join(dir, "b", "icon.png")},
+		outputFilename: filepath.Join(dir, "out") + string(os.PathSeparator),
+		jobs:           2,
+	}
+	if err := RunBatch(&c); err == nil || !strings.Contains(err.Error(), "would be written to") {
+		t.Errorf("Expected an error about the colliding output files, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out")); err == nil {
+		t.Error("Expected nothing to be written")
+	}
+}
+
+func TestUpToDate(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, "icon.png", "icon.svg")
+	in := input{filepath.Join(dir, "icon.png"), "."}
+	output := filepath.Join(dir, "icon.svg")
+
+	// The files are not valid PNG images, so they can only be skipped, not converted
+	now := time.Now()
+	if err := os.Chtimes(in.filename, now, now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if !upToDate(in.filename, output) {
+		t.Error("Expected the output to be up to date")
+	}
+	c := Config{update: "mtime"}
+	if res := convert(c, in, output, nil); !res.skipped || res.err != nil {
+		t.Errorf("Expected the conversion to be skipped, got %+v", res)
+	}
+	if err := os.Chtimes(in.filename, now, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if upToDate(in.filename, output) {
+		t.Error("Expected the output to be out of date")
+	}
+	if res := convert(c, in, output, nil); res.skipped || res.err == nil {
+		t.Errorf("Expected a conversion to be attempted, got %+v", res)
+	}
+
+	// With -u hash, the checksum of the input and the settings must match the stored one
+	c = Config{update: "hash"}
+	sum, err := checksum(in.filename, c.settings())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checksumFilename := filepath.Join(dir, ".png2svg.sum")
+	if err := writeChecksums(checksumFilename, map[string]string{output: sum}); err != nil {
+		t.Fatal(err)
+	}
+	checksums, err := readChecksums(checksumFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := convert(c, in, output, checksums); !res.skipped || res.err != nil || res.inputChecksum != sum {
+		t.Errorf("Expected the conversion to be skipped, got %+v", res)
+	}
+	c.limit = true
+	if res := convert(c, in, output, checksums); res.skipped {
+		t.Error("Expected changed settings to give a different checksum")
+	}
+	if err := os.WriteFile(in.filename, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c.limit = false
+	if res := convert(c, in, output, checksums); res.skipped {
+		t.Error("Expected a changed input file to give a different checksum")
+	}
+}
@@ -4,7 +4,13 @@ package main
 import (
 	"errors"
 	"fmt"
+	"image"
+	"image/png"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 
 	"github.com/urfave/cli/v2"
 	"github.com/xyproto/palgen"
@@ -14,7 +20,15 @@ import (
 // Config contains the results of parsing the flags and arguments
 type Config struct {
 	inputFilename         string
+	inputFilenames        []string
 	outputFilename        string
+	diffFilename          string
+	stats                 string
+	dryRun                bool
+	maxSize               int
+	conversionStats       *png2svg.Stats
+	update                string
+	checksumFilename      string
 	colorOptimize         bool
 	colorPink             bool
 	limit                 bool
@@ -23,6 +37,40 @@ type Config struct {
 	verbose               bool
 	version               bool
 	palReduction          int
+	jobs                  int
+	gzip                  bool
+	precompress           bool
+	compressionLevel      int
+	crop                  string
+	rotate                int
+	flip                  string
+	downscale             string
+	grid                  bool
+	gridTolerance         int
+	scale                 float64
+	width                 float64
+	height                float64
+	crispEdges            bool
+	compact               bool
+	format                string
+	metadata              png2svg.Metadata
+	sourceMetadata        bool
+	editable              bool
+	regions               bool
+	profile               string
+	encoding              string
+	background            string
+	transparent           cli.StringSlice
+	matte                 string
+	imageOptions          png2svg.ImageOptions
+	tiles                 string
+	gradients             bool
+	gradientTolerance     int
+	shapes                bool
+	shapeTolerance        float64
+	growth                string
+	mode                  string
+	traceOptions          png2svg.TraceOptions
 }
 
 func main() {
@@ -34,9 +82,46 @@ func main() {
 			&cli.StringFlag{
 				Name:        "o",
 				Value:       "-",
-				Usage:       "SVG output filename",
+				Usage:       "SVG output filename, directory or template like out/{dir}/{name}.svg",
 				Destination: &config.outputFilename,
 			},
+			&cli.StringFlag{
+				Name:        "diff",
+				Usage:       "render the SVG image and write a heatmap of the differences from the PNG image to this PNG file",
+				Destination: &config.diffFilename,
+			},
+			&cli.StringFlag{
+				Name:        "stats",
+				Usage:       "print statistics about the conversion as \"text\" or \"json\", instead of the summary",
+				Destination: &config.stats,
+			},
+			&cli.BoolFlag{
+				Name:        "dry-run",
+				Usage:       "place the rectangles and print the estimated size of the SVG image, without writing it",
+				Destination: &config.dryRun,
+			},
+			&cli.IntFlag{
+				Name:        "max-size",
+				Usage:       "refuse to write SVG images that are estimated to be larger than this many bytes, after compressing or embedding them",
+				Destination: &config.maxSize,
+			},
+			&cli.IntFlag{
+				Name:        "j",
+				Value:       runtime.NumCPU(),
+				Usage:       "number of files to convert in parallel",
+				Destination: &config.jobs,
+			},
+			&cli.StringFlag{
+				Name:        "u",
+				Usage:       "skip files where the output is up to date, by comparing \"mtime\" or \"hash\"",
+				Destination: &config.update,
+			},
+			&cli.StringFlag{
+				Name:        "hashfile",
+				Value:       ".png2svg.sum",
+				Usage:       "where to store checksums when using -u hash",
+				Destination: &config.checksumFilename,
+			},
 			&cli.BoolFlag{
 				Name:        "p",
 				Usage:       "use only single pixel rectangles",
@@ -73,6 +158,218 @@ func main() {
 				Usage:       "deprecated (same as -l)",
 				Destination: &config.colorOptimize,
 			},
+			&cli.BoolFlag{
+				Name:        "gzip",
+				Usage:       "write gzip compressed SVGZ (default when -o ends with .svgz)",
+				Destination: &config.gzip,
+			},
+			&cli.IntFlag{
+				Name:        "level",
+				Value:       9,
+				Usage:       "gzip compression level, from 1 to 9",
+				Destination: &config.compressionLevel,
+			},
+			&cli.BoolFlag{
+				Name:        "precompress",
+				Usage:       "also write a gzip compressed copy to the output filename + .gz, for static hosting (Brotli is not supported)",
+				Destination: &config.precompress,
+			},
+			&cli.StringFlag{
+				Name:        "crop",
+				Usage:       "only convert the part of the PNG image given as \"x,y,width,height\"",
+				Destination: &config.crop,
+			},
+			&cli.IntFlag{
+				Name:        "rotate",
+				Usage:       "rotate the PNG image clockwise by 90, 180 or 270 degrees",
+				Destination: &config.rotate,
+			},
+			&cli.StringFlag{
+				Name:        "flip",
+				Usage:       "mirror the PNG image: \"horizontal\", \"vertical\" or \"both\"",
+				Destination: &config.flip,
+			},
+			&cli.StringFlag{
+				Name:        "downscale",
+				Usage:       "convert upscaled pixel art at its native resolution, given the pixel size or \"auto\"",
+				Destination: &config.downscale,
+			},
+			&cli.BoolFlag{
+				Name:        "grid",
+				Usage:       "detect the grid of blocks in screenshots of upscaled pixel art, and convert it at its native resolution",
+				Destination: &config.grid,
+			},
+			&cli.IntFlag{
+				Name:        "grid-tolerance",
+				Value:       8,
+				Usage:       "how much each color channel may differ within a block of the grid, from 0 to 255",
+				Destination: &config.gridTolerance,
+			},
+			&cli.Float64Flag{
+				Name:        "scale",
+				Usage:       "display the SVG image at the size of the PNG image multiplied with the given scale (not with --width or --height)",
+				Destination: &config.scale,
+			},
+			&cli.Float64Flag{
+				Name:        "width",
+				Usage:       "display width of the SVG image (the height is calculated if not given)",
+				Destination: &config.width,
+			},
+			&cli.Float64Flag{
+				Name:        "height",
+				Usage:       "display height of the SVG image (the width is calculated if not given)",
+				Destination: &config.height,
+			},
+			&cli.BoolFlag{
+				Name:        "crisp",
+				Usage:       "add shape-rendering=\"crispEdges\", for pixel art",
+				Destination: &config.crispEdges,
+			},
+			&cli.BoolFlag{
+				Name:        "compact",
+				Usage:       "leave out the XML declaration and px units",
+				Destination: &config.compact,
+			},
+			&cli.StringFlag{
+				Name:        "format",
+				Value:       "svg",
+				Usage:       "output format: \"svg\", \"datauri\", \"css\", \"img\", \"html\" (inline <svg>), \"jsx\" (React component), \"go\" ([]byte variable) or \"gofunc\" (tinysvg function)",
+				Destination: &config.format,
+			},
+			&cli.StringFlag{
+				Name:        "title",
+				Usage:       "add a <title>, role=\"img\" and aria-labelledby, so that screen readers can announce the image",
+				Destination: &config.metadata.Title,
+			},
+			&cli.StringFlag{
+				Name:        "desc",
+				Usage:       "add a <desc> with a longer description of the image",
+				Destination: &config.metadata.Description,
+			},
+			&cli.StringFlag{
+				Name:        "author",
+				Usage:       "add the name of the author to the RDF metadata",
+				Destination: &config.metadata.Author,
+			},
+			&cli.StringFlag{
+				Name:        "license",
+				Usage:       "add the URL of the license to the RDF metadata",
+				Destination: &config.metadata.License,
+			},
+			&cli.BoolFlag{
+				Name:        "metadata",
+				Usage:       "add the PNG filename and the png2svg version to the RDF metadata",
+				Destination: &config.sourceMetadata,
+			},
+			&cli.BoolFlag{
+				Name:        "editable",
+				Usage:       "place each color in a <g> layer with an id, inkscape:label and data-color, ordered by area, for editing in Inkscape",
+				Destination: &config.editable,
+			},
+			&cli.BoolFlag{
+				Name:        "regions",
+				Usage:       "like --editable, but also place each connected region of a color in its own <g> layer",
+				Destination: &config.regions,
+			},
+			&cli.StringFlag{
+				Name:        "profile",
+				Usage:       "the version of SVG that the SVG image conforms to: \"tiny\" (SVG Tiny 1.2), \"1.1\" or \"2\" (default \"tiny\", or \"2\" with --title, --desc, --editable or --regions)",
+				Destination: &config.profile,
+			},
+			&cli.StringFlag{
+				Name:        "encoding",
+				Usage:       "how rectangles are written: \"rect\" for <rect> elements or \"path\" for one <path> per color (default \"rect\", or \"path\" with --compact)",
+				Destination: &config.encoding,
+			},
+			&cli.StringSliceFlag{
+				Name:        "transparent",
+				Usage:       "treat pixels with this key color, like #ff00ff, as transparent (can be given more than once)",
+				Destination: &config.transparent,
+			},
+			&cli.IntFlag{
+				Name:        "transparent-tolerance",
+				Usage:       "how much each color channel may differ from a transparent key color, from 0 to 255",
+				Destination: &config.imageOptions.TransparentTolerance,
+			},
+			&cli.IntFlag{
+				Name:        "alpha-threshold",
+				Value:       1,
+				Usage:       "pixels with an alpha value below this, from 0 to 255, are transparent",
+				Destination: &config.imageOptions.AlphaThreshold,
+			},
+			&cli.BoolFlag{
+				Name:        "binarize",
+				Usage:       "make all pixels that are not transparent fully opaque",
+				Destination: &config.imageOptions.Binarize,
+			},
+			&cli.StringFlag{
+				Name:        "matte",
+				Usage:       "composite pixels that are not fully opaque over this color, like #ffffff",
+				Destination: &config.matte,
+			},
+			&cli.StringFlag{
+				Name:        "background",
+				Value:       "keep",
+				Usage:       "\"auto\" draws the detected background color once, \"none\" removes it, \"#rrggbb\" draws the given color once, \"keep\" does nothing",
+				Destination: &config.background,
+			},
+			&cli.StringFlag{
+				Name:        "tiles",
+				Usage:       "draw repeated tiles of the given sizes only once, like \"16\", \"16x8\" or \"32,16\" (only tiles on a grid of that size, starting in the upper left corner, are found)",
+				Destination: &config.tiles,
+			},
+			&cli.BoolFlag{
+				Name:        "gradients",
+				Usage:       "draw areas where the color changes linearly with a single <rect> and a <linearGradient>",
+				Destination: &config.gradients,
+			},
+			&cli.IntFlag{
+				Name:        "gradient-tolerance",
+				Value:       2,
+				Usage:       "how much each color channel may differ from a detected gradient, from 0 to 255",
+				Destination: &config.gradientTolerance,
+			},
+			&cli.BoolFlag{
+				Name:        "shapes",
+				Usage:       "draw areas that are shaped like circles or ellipses with <circle> and <ellipse>",
+				Destination: &config.shapes,
+			},
+			&cli.Float64Flag{
+				Name:        "shape-tolerance",
+				Value:       0.05,
+				Usage:       "the fraction of pixels that may differ from a detected circle or ellipse, from 0 to 1",
+				Destination: &config.shapeTolerance,
+			},
+			&cli.StringFlag{
+				Name:        "growth",
+				Value:       "right-down",
+				Usage:       "how rectangles are expanded: right-down, down-right, alternating, square or largest",
+				Destination: &config.growth,
+			},
+			&cli.StringFlag{
+				Name:        "mode",
+				Value:       "rect",
+				Usage:       "conversion mode: \"rect\" draws rectangles, \"trace\" traces smooth outlines, \"pixelart\" smooths pixel art",
+				Destination: &config.mode,
+			},
+			&cli.Float64Flag{
+				Name:        "tolerance",
+				Value:       png2svg.NewTraceOptions().Tolerance,
+				Usage:       "how far, in pixels, traced outlines may be simplified",
+				Destination: &config.traceOptions.Tolerance,
+			},
+			&cli.Float64Flag{
+				Name:        "corner",
+				Value:       png2svg.NewTraceOptions().CornerThreshold,
+				Usage:       "traced outlines that turn more than this angle, in degrees, get sharp corners",
+				Destination: &config.traceOptions.CornerThreshold,
+			},
+			&cli.Float64Flag{
+				Name:        "smoothing",
+				Value:       png2svg.NewTraceOptions().Smoothing,
+				Usage:       "how round the traced curves are, 0 gives straight lines",
+				Destination: &config.traceOptions.Smoothing,
+			},
 			&cli.IntFlag{
 				Name:        "n",
 				Value:       0,
@@ -95,9 +392,25 @@ func main() {
 			if c.Args().Len() == 0 {
 				return errors.New("an input PNG filename is required")
 			}
-			config.inputFilename = c.Args().First()
+			config.inputFilenames = c.Args().Slice()
+
+			switch config.update {
+			case "", "mtime", "hash":
+			default:
+				return fmt.Errorf("invalid -u value %q, must be \"mtime\" or \"hash\"", config.update)
+			}
+
+			switch config.stats {
+			case "", "text", "json":
+			default:
+				return fmt.Errorf("invalid --stats value %q, must be \"text\" or \"json\"", config.stats)
+			}
+
+			if err := checkFlags(&config); err != nil {
+				return err
+			}
 
-			return Run(&config)
+			return RunBatch(&config)
 		},
 	}
 
@@ -108,7 +421,39 @@ func main() {
 	}
 }
 
-// Run performs the user-selected operations
+// formats maps the values of --format to output formats
+var formats = map[string]png2svg.Format{
+	"svg":     png2svg.SVGFormat,
+	"datauri": png2svg.DataURIFormat,
+	"css":     png2svg.CSSFormat,
+	"img":     png2svg.ImageFormat,
+	"html":    png2svg.InlineFormat,
+	"jsx":     png2svg.JSXFormat,
+	"go":      png2svg.GoFormat,
+	"gofunc":  png2svg.GoFuncFormat,
+}
+
+// checkFlags checks for flags that can not be combined, and selects the defaults that depend on other flags
+func checkFlags(c *Config) error {
+	if c.scale != 0 && (c.width != 0 || c.height != 0) {
+		return errors.New("--scale can not be combined with --width or --height")
+	}
+	// aria-labelledby and data-color are only a part of SVG 2, which is the default when they are needed
+	if c.metadata.Title != "" || c.metadata.Description != "" || c.editable || c.regions {
+		switch c.profile {
+		case "":
+			c.profile = "2"
+		case "2":
+		default:
+			return fmt.Errorf("--title, --desc, --editable and --regions need --profile 2, since profile %q has no aria-labelledby or data-color attributes", c.profile)
+		}
+	} else if c.profile == "" {
+		c.profile = "tiny"
+	}
+	return nil
+}
+
+// Run performs the user-selected operations on a single input file
 func Run(c *Config) error {
 	var (
 		box          *png2svg.Box
@@ -119,11 +464,19 @@ func Run(c *Config) error {
 		done         bool
 	)
 
+	start := time.Now()
 	img, err := png2svg.ReadPNG(c.inputFilename, c.verbose)
 	if err != nil {
 		return err
 	}
 
+	img, pixelSize, err := preprocess(c, img)
+	if err != nil {
+		return err
+	}
+
+	// The differences that are shown with --diff include the reduction of the palette
+	source := img
 	if c.palReduction > 0 {
 		img, err = palgen.Reduce(img, c.palReduction)
 		if err != nil {
@@ -133,9 +486,186 @@ func Run(c *Config) error {
 
 	height := img.Bounds().Max.Y - img.Bounds().Min.Y
 
-	pi := png2svg.NewPixelImage(img, c.verbose)
+	opts := c.imageOptions
+	opts.TransparentColors = nil
+	for _, s := range c.transparent.Value() {
+		key, err := png2svg.ParseHexColor(s)
+		if err != nil {
+			return err
+		}
+		opts.TransparentColors = append(opts.TransparentColors, key)
+	}
+	if c.matte != "" {
+		matte, err := png2svg.ParseHexColor(c.matte)
+		if err != nil {
+			return err
+		}
+		opts.Matte = &matte
+	}
+	readDuration := time.Since(start)
+	pi, err := png2svg.NewPixelImageWithOptions(img, c.verbose, &opts)
+	if err != nil {
+		return err
+	}
+	pixelWidth, pixelHeight := float64(pixelSize), float64(pixelSize)
+	var grid *png2svg.Grid
+	if c.grid {
+		// Only use grids that fit the image well
+		const minGridConfidence = 0.8
+		grid = pi.DetectGrid(c.gridTolerance)
+		if grid.Confidence >= minGridConfidence && (grid.PitchX > 1 || grid.PitchY > 1) {
+			if pi, err = png2svg.NewPixelImageWithOptions(pi.NativeImage(grid), c.verbose, &opts); err != nil {
+				return err
+			}
+			pixelWidth *= float64(grid.PitchX)
+			pixelHeight *= float64(grid.PitchY)
+		} else {
+			grid = nil
+		}
+	}
+	pi.RecordPhase("read", readDuration)
+	reference := pi
+	if c.diffFilename != "" && c.palReduction > 0 {
+		if reference, err = png2svg.NewPixelImageWithOptions(source, false, &opts); err != nil {
+			return err
+		}
+		if grid != nil {
+			if reference, err = png2svg.NewPixelImageWithOptions(reference.NativeImage(grid), false, &opts); err != nil {
+				return err
+			}
+		}
+	}
 	pi.SetColorOptimize(c.limit)
+	pi.SetGzip(c.gzip)
+	pi.SetPrecompress(c.precompress)
+	if err := pi.SetCompressionLevel(c.compressionLevel); err != nil {
+		return err
+	}
+	if (pixelWidth > 1 || pixelHeight > 1) && c.width == 0 && c.height == 0 {
+		// Show the downscaled image at the original size
+		scale := max(c.scale, 1)
+		if err := pi.SetPixelSize(pixelWidth*scale, pixelHeight*scale); err != nil {
+			return err
+		}
+	} else if c.scale != 0 {
+		if err := pi.SetScale(c.scale); err != nil {
+			return err
+		}
+	} else if c.width != 0 || c.height != 0 {
+		if err := pi.SetDisplaySize(c.width, c.height); err != nil {
+			return err
+		}
+	}
+	pi.SetCrispEdges(c.crispEdges)
+	pi.SetCompact(c.compact)
+	format, ok := formats[c.format]
+	if !ok {
+		return fmt.Errorf("invalid format %q, must be \"svg\", \"datauri\", \"css\", \"img\", \"html\", \"jsx\", \"go\" or \"gofunc\"", c.format)
+	}
+	pi.SetFormat(format, strings.TrimSuffix(filepath.Base(c.inputFilename), filepath.Ext(c.inputFilename)))
+	if metadata := c.metadata; metadata != (png2svg.Metadata{}) || c.sourceMetadata {
+		if c.sourceMetadata {
+			metadata.Source = filepath.Base(c.inputFilename)
+			metadata.Generator = true
+		}
+		pi.SetMetadata(&metadata)
+	}
+	pi.SetEditable(c.editable || c.regions, c.regions)
+	profile, err := png2svg.ProfileByName(c.profile)
+	if err != nil {
+		return err
+	}
+	pi.SetProfile(profile)
+	switch c.encoding {
+	case "":
+		// The default is "path" with --compact, which is selected by SetCompact
+		if !c.compact {
+			pi.SetEncoding(png2svg.RectEncoding)
+		}
+	case "rect":
+		pi.SetEncoding(png2svg.RectEncoding)
+	case "path":
+		pi.SetEncoding(png2svg.PathEncoding)
+	default:
+		return fmt.Errorf("invalid encoding %q, must be \"rect\" or \"path\"", c.encoding)
+	}
+
+	growth, err := png2svg.GrowthStrategyByName(c.growth)
+	if err != nil {
+		return err
+	}
+	pi.SetGrowthStrategy(growth)
+
+	switch c.background {
+	case "keep":
+	case "auto", "none":
+		background, ok := pi.DetectBackground()
+		if !ok {
+			break
+		}
+		if c.background == "auto" {
+			// Images with transparent pixels keep their background as it is
+			if _, err := pi.DrawBackground(background); err != nil && c.verbose {
+				fmt.Println(err)
+			}
+		} else {
+			pi.RemoveBackground(background)
+		}
+	default:
+		background, err := png2svg.ParseHexColor(c.background)
+		if err != nil {
+			return fmt.Errorf("invalid background %q, must be \"auto\", \"none\", \"keep\" or a color like #ffffff", c.background)
+		}
+		if _, err := pi.DrawBackground(background); err != nil {
+			return fmt.Errorf("%v, use --matte to fill transparent pixels", err)
+		}
+	}
+
+	if c.tiles != "" {
+		sizes, err := parseTileSizes(c.tiles)
+		if err != nil {
+			return err
+		}
+		for _, size := range sizes {
+			if _, err := pi.DeduplicateTiles(size[0], size[1]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.gradients {
+		// Gradients that are shorter than this are drawn just as well with rectangles
+		const minGradientLength = 4
+		if _, err := pi.DetectGradients(c.gradientTolerance, minGradientLength); err != nil {
+			return err
+		}
+	}
+
+	if c.shapes {
+		// Smaller circles and ellipses are drawn just as well with rectangles
+		const minShapeSize = 3
+		if _, err := pi.DetectEllipses(c.shapeTolerance, minShapeSize); err != nil {
+			return err
+		}
+	}
 
+	switch c.mode {
+	case "rect":
+	case "trace":
+		if err := pi.Trace(&c.traceOptions); err != nil {
+			return err
+		}
+		return writeOutput(c, pi, reference)
+	case "pixelart":
+		if err := pi.TracePixelArt(&c.traceOptions); err != nil {
+			return err
+		}
+		return writeOutput(c, pi, reference)
+	default:
+		return fmt.Errorf("invalid mode %q, must be \"rect\", \"trace\" or \"pixelart\"", c.mode)
+	}
+
+	start = time.Now()
 	percentage := 0
 	lastPercentage := 0
 
@@ -160,7 +690,7 @@ func Run(c *Config) error {
 
 			// Create a box at that location
 			box = pi.CreateBox(x, y)
-			// Expand the box to the right and downwards, until it can not expand anymore
+			// Expand the box with the selected growth strategy, until it can not expand anymore
 			expanded = pi.Expand(box)
 
 			// Use the expanded box. Color pink if it is > 1x1, and colorPink is true
@@ -197,6 +727,130 @@ func Run(c *Config) error {
 		}
 	}
 
+	pi.RecordPhase("cover", time.Since(start))
+
 	// Write the SVG image to outputFilename
-	return pi.WriteSVG(c.outputFilename)
+	return writeOutput(c, pi, reference)
+}
+
+// writeOutput writes the SVG image to the output file. If --diff is given, the SVG image is
+// rendered and compared with the colors of the reference image, and a heatmap is written.
+func writeOutput(c *Config, pi, reference *png2svg.PixelImage) error {
+	if c.dryRun || c.maxSize > 0 {
+		// Estimate the size before rendering the SVG document, which can be slow for large images
+		estimate := pi.EstimateSize()
+		if c.dryRun {
+			fmt.Printf("%s: %s\n", c.inputFilename, estimate)
+			return nil
+		}
+		// Compressed and embedded SVG images are rendered, to find the size of what is written
+		size, err := pi.OutputSize(c.outputFilename)
+		if err != nil {
+			return err
+		}
+		if size > c.maxSize {
+			return fmt.Errorf("the SVG image would be about %d bytes, which is larger than the maximum of %d bytes", size, c.maxSize)
+		}
+		if c.verbose {
+			fmt.Println(estimate)
+		}
+	}
+	if err := pi.WriteSVG(c.outputFilename); err != nil {
+		return err
+	}
+	c.conversionStats = pi.Stats()
+	if c.diffFilename == "" {
+		return nil
+	}
+	rendered, err := png2svg.Rasterize(pi.Bytes())
+	if err != nil {
+		return err
+	}
+	stats, heatmap, err := reference.Diff(rendered)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(c.diffFilename)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, heatmap); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	// The SVG image may be written to stdout
+	fmt.Fprintf(os.Stderr, "%s: %s\n", c.inputFilename, stats)
+	return nil
+}
+
+// parseTileSizes parses a comma separated list of tile sizes, like "32,16x8"
+func parseTileSizes(s string) ([][2]int, error) {
+	var sizes [][2]int
+	for _, field := range strings.Split(s, ",") {
+		var w, h int
+		if strings.Contains(field, "x") {
+			if _, err := fmt.Sscanf(field, "%dx%d", &w, &h); err != nil {
+				return nil, fmt.Errorf("invalid tile size %q", field)
+			}
+		} else {
+			if _, err := fmt.Sscanf(field, "%d", &w); err != nil {
+				return nil, fmt.Errorf("invalid tile size %q", field)
+			}
+			h = w
+		}
+		sizes = append(sizes, [2]int{w, h})
+	}
+	return sizes, nil
+}
+
+// preprocess crops, rotates, flips and downscales the image, as given by the configuration.
+// Returns the preprocessed image and the pixel size it was downscaled by.
+func preprocess(c *Config, img image.Image) (image.Image, int, error) {
+	var err error
+	if c.crop != "" {
+		var x, y, w, h int
+		if _, err := fmt.Sscanf(c.crop, "%d,%d,%d,%d", &x, &y, &w, &h); err != nil {
+			return nil, 0, fmt.Errorf("invalid crop rectangle %q, must be \"x,y,width,height\"", c.crop)
+		}
+		if img, err = png2svg.Crop(img, image.Rect(x, y, x+w, y+h)); err != nil {
+			return nil, 0, err
+		}
+	}
+	if c.rotate != 0 {
+		if img, err = png2svg.Rotate(img, c.rotate); err != nil {
+			return nil, 0, err
+		}
+	}
+	switch c.flip {
+	case "":
+	case "horizontal":
+		img = png2svg.Flip(img, true, false)
+	case "vertical":
+		img = png2svg.Flip(img, false, true)
+	case "both":
+		img = png2svg.Flip(img, true, true)
+	default:
+		return nil, 0, fmt.Errorf("invalid flip %q, must be \"horizontal\", \"vertical\" or \"both\"", c.flip)
+	}
+	pixelSize := 1
+	switch c.downscale {
+	case "":
+	case "auto":
+		img, pixelSize = png2svg.Downscale(img)
+		if c.verbose {
+			fmt.Printf("Detected a pixel size of %d\n", pixelSize)
+		}
+	default:
+		if _, err := fmt.Sscanf(c.downscale, "%d", &pixelSize); err != nil || pixelSize < 1 {
+			return nil, 0, fmt.Errorf("invalid downscale %q, must be a pixel size or \"auto\"", c.downscale)
+		}
+		b := img.Bounds()
+		if img, err = png2svg.ScaleNearest(img, b.Dx()/pixelSize, b.Dy()/pixelSize); err != nil {
+			return nil, 0, err
+		}
+	}
+	return img, pixelSize, nil
 }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/xyproto/png2svg"
+)
+
+func TestCheckFlags(t *testing.T) {
+	for _, tc := range []struct {
+		c       Config
+		profile string // the selected profile, or "" for an error
+	}{
+		{Config{}, "tiny"},
+		{Config{profile: "1.1"}, "1.1"},
+		{Config{metadata: png2svg.Metadata{Title: "Logo"}}, "2"},
+		{Config{metadata: png2svg.Metadata{Author: "Bob"}}, "tiny"},
+		{Config{editable: true, profile: "2"}, "2"},
+		{Config{regions: true}, "2"},
+		{Config{metadata: png2svg.Metadata{Description: "A logo"}, profile: "tiny"}, ""},
+		{Config{editable: true, profile: "1.1"}, ""},
+	} {
+		err := checkFlags(&tc.c)
+		switch {
+		case tc.profile == "" && err == nil:
+			t.Errorf("Expected an error for %+v", tc.c)
+		case tc.profile != "" && (err != nil || tc.c.profile != tc.profile):
+			t.Errorf("Expected the profile %q, got %q (%v)", tc.profile, tc.c.profile, err)
+		}
+	}
+}
+
+func TestCheckFlagsDisplaySize(t *testing.T) {
+	for _, c := range []Config{{scale: 2, width: 64}, {scale: 2, height: 64}, {scale: 2, width: 64, height: 64}} {
+		if err := checkFlags(&c); err == nil {
+			t.Errorf("Expected an error for --scale with --width or --height: %+v", c)
+		}
+	}
+	for _, c := range []Config{{scale: 2}, {width: 64, height: 32}} {
+		if err := checkFlags(&c); err != nil {
+			t.Errorf("Expected no error, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package main is the main package for the svg2png utility, which renders
+// SVG images that have been created by png2svg back to PNG images
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+	"github.com/xyproto/png2svg"
+)
+
+// errDiffers is returned when the rendered image differs from the reference image
+var errDiffers = errors.New("the rendered image differs from the reference image")
+
+// Config contains the results of parsing the flags and arguments
+type Config struct {
+	inputFilename     string
+	outputFilename    string
+	referenceFilename string
+	tolerance         int
+	verbose           bool
+	version           bool
+}
+
+func main() {
+	var config Config
+	app := &cli.App{
+		Name:      "svg2png",
+		Usage:     "Render SVG images that have been created by png2svg to PNG format",
+		ArgsUsage: "INPUT.svg",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:        "o",
+				Usage:       "PNG output filename or \"-\" for stdout, the default is the input filename with .png, unless --reference is given",
+				Destination: &config.outputFilename,
+			},
+			&cli.StringFlag{
+				Name:        "reference",
+				Aliases:     []string{"r"},
+				Usage:       "compare the rendered image with this PNG image, and exit with status 2 if they differ",
+				Destination: &config.referenceFilename,
+			},
+			&cli.IntFlag{
+				Name:        "tolerance",
+				Value:       0,
+				Usage:       "how much each color channel may differ from the reference image, from 0 to 255",
+				Destination: &config.tolerance,
+			},
+			&cli.BoolFlag{
+				Name:        "v",
+				Usage:       "verbose",
+				Destination: &config.verbose,
+			},
+			&cli.BoolFlag{
+				Name:        "version",
+				Usage:       "print the version",
+				Aliases:     []string{"V"},
+				Destination: &config.version,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			if c.Bool("version") {
+				fmt.Println(strings.Replace(png2svg.VersionString, "png2svg", "svg2png", 1))
+				return nil
+			}
+			if c.Args().Len() != 1 {
+				return errors.New("one input SVG filename is required")
+			}
+			config.inputFilename = c.Args().First()
+			if config.tolerance < 0 || config.tolerance > 255 {
+				return errors.New("the tolerance must be from 0 to 255")
+			}
+			if config.outputFilename == "" && config.referenceFilename == "" {
+				config.outputFilename = strings.TrimSuffix(config.inputFilename, filepath.Ext(config.inputFilename)) + ".png"
+			}
+			return Run(&config)
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err.Error())
+		if err == errDiffers {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+// Run renders the input file, writes the PNG image and compares it with the reference image
+func Run(c *Config) error {
+	data, err := os.ReadFile(c.inputFilename)
+	if err != nil {
+		return err
+	}
+	img, err := png2svg.Rasterize(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.inputFilename, err)
+	}
+	if c.verbose {
+		fmt.Fprintf(os.Stderr, "Rendered %s at %dx%d\n", c.inputFilename, img.Bounds().Dx(), img.Bounds().Dy())
+	}
+
+	switch c.outputFilename {
+	case "":
+	case "-":
+		if err := png.Encode(os.Stdout, img); err != nil {
+			return err
+		}
+	default:
+		f, err := os.Create(c.outputFilename)
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(f, img); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	if c.referenceFilename == "" {
+		return nil
+	}
+	reference, err := png2svg.ReadPNG(c.referenceFilename, false)
+	if err != nil {
+		return err
+	}
+	differences, err := png2svg.CompareImages(reference, img, c.tolerance)
+	if err != nil {
+		return err
+	}
+	if c.verbose || differences > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d pixels differ from %s\n", differences, img.Bounds().Dx()*img.Bounds().Dy(), c.referenceFilename)
+	}
+	if differences > 0 {
+		return errDiffers
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package png2svg
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// heatmapMaxDeltaE is the color difference that is shown with the hottest color in the heatmap
+const heatmapMaxDeltaE = 25
+
+// DiffStats contains the differences between the original image and a rendering of the SVG image
+type DiffStats struct {
+	Pixels     int     // the number of pixels in the image
+	Changed    int     // the number of pixels where any color channel or the alpha value differs
+	MaxError   int     // the largest difference in a color channel or the alpha value, from 0 to 255
+	MaxDeltaE  float64 // the largest color difference, as CIE76 ΔE
+	MeanDeltaE float64 // the mean color difference of all the pixels, as CIE76 ΔE
+}
+
+// String returns a short summary of the differences
+func (ds *DiffStats) String() string {
+	percentage := 0.0
+	if ds.Pixels > 0 {
+		percentage = 100 * float64(ds.Changed) / float64(ds.Pixels)
+	}
+	return fmt.Sprintf("%d of %d pixels changed (%.2f%%), max error %d, max ΔE %.2f, mean ΔE %.4f",
+		ds.Changed, ds.Pixels, percentage, ds.MaxError, ds.MaxDeltaE, ds.MeanDeltaE)
+}
+
+// linear converts an sRGB color channel from 0 to 1 to linear light
+func linear(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+// lab converts an sRGB color, with channels from 0 to 1, to CIE L*a*b*, with the D65 white point
+func lab(r, g, b float64) [3]float64 {
+	r, g, b = linear(r), linear(g), linear(b)
+	x := (0.4124*r + 0.3576*g + 0.1805*b) / 0.95047
+	y := 0.2126*r + 0.7152*g + 0.0722*b
+	z := (0.0193*r + 0.1192*g + 0.9505*b) / 1.08883
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return [3]float64{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
+
+// deltaE returns the CIE76 color difference between two colors with alpha. Since the colors may
+// be shown over any background, they are composited over both black and white, and the largest
+// difference is used.
+func deltaE(p, q color.NRGBA) float64 {
+	worst := 0.0
+	for _, background := range []float64{0, 1} {
+		over := func(c color.NRGBA) [3]float64 {
+			a := float64(c.A) / 0xff
+			mix := func(v uint8) float64 { return float64(v)/0xff*a + background*(1-a) }
+			return lab(mix(c.R), mix(c.G), mix(c.B))
+		}
+		lp, lq := over(p), over(q)
+		d := math.Sqrt((lp[0]-lq[0])*(lp[0]-lq[0]) + (lp[1]-lq[1])*(lp[1]-lq[1]) + (lp[2]-lq[2])*(lp[2]-lq[2]))
+		worst = math.Max(worst, d)
+	}
+	return worst
+}
+
+// heatColor returns the color of a changed pixel in the heatmap, from blue for small
+// differences, through red, to yellow for differences of heatmapMaxDeltaE or more
+func heatColor(d float64) color.NRGBA {
+	// Even the smallest changes should be visible
+	t := math.Max(0.05, math.Min(1, d/heatmapMaxDeltaE))
+	if t < 0.5 {
+		return color.NRGBA{uint8(math.Round(510 * t)), 0, uint8(math.Round(255 * (1 - 2*t))), 0xff}
+	}
+	return color.NRGBA{0xff, uint8(math.Round(510 * (t - 0.5))), 0, 0xff}
+}
+
+// Diff compares a rendering of the SVG image, for instance from Rasterize, with the original
+// colors of the pixels, and returns statistics about the differences together with a heatmap.
+// In the heatmap, pixels that are unchanged are a dark gray version of the original image,
+// while changed pixels go from blue to red to yellow, as the color difference grows.
+func (pi *PixelImage) Diff(rendered image.Image) (*DiffStats, *image.NRGBA, error) {
+	b := rendered.Bounds()
+	if b.Dx() != pi.w || b.Dy() != pi.h {
+		return nil, nil, fmt.Errorf("the rendered image is %dx%d, but the original image is %dx%d", b.Dx(), b.Dy(), pi.w, pi.h)
+	}
+	stats := &DiffStats{Pixels: len(pi.pixels)}
+	heatmap := image.NewNRGBA(image.Rect(0, 0, pi.w, pi.h))
+	sum := 0.0
+	for _, p := range pi.pixels {
+		original := color.NRGBA{uint8(p.r), uint8(p.g), uint8(p.b), uint8(p.a)}
+		c := color.NRGBAModel.Convert(rendered.At(b.Min.X+p.x, b.Min.Y+p.y)).(color.NRGBA)
+		if original.A == 0 && c.A == 0 {
+			heatmap.SetNRGBA(p.x, p.y, color.NRGBA{0, 0, 0, 0xff})
+			continue
+		}
+		maxError := max(absDiff(original.R, c.R), absDiff(original.G, c.G), absDiff(original.B, c.B), absDiff(original.A, c.A))
+		if maxError == 0 {
+			luma := (299*int(original.R) + 587*int(original.G) + 114*int(original.B)) / 1000
+			gray := uint8(0x20 + luma*int(original.A)/0xff/4)
+			heatmap.SetNRGBA(p.x, p.y, color.NRGBA{gray, gray, gray, 0xff})
+			continue
+		}
+		d := deltaE(original, c)
+		stats.Changed++
+		stats.MaxError = max(stats.MaxError, maxError)
+		stats.MaxDeltaE = math.Max(stats.MaxDeltaE, d)
+		sum += d
+		heatmap.SetNRGBA(p.x, p.y, heatColor(d))
+	}
+	if stats.Pixels > 0 {
+		stats.MeanDeltaE = sum / float64(stats.Pixels)
+	}
+	return stats, heatmap, nil
+}
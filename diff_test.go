@@ -0,0 +1,62 @@
+package png2svg
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{0, 0, 0, 0xff})
+	img.SetNRGBA(1, 0, color.NRGBA{0x80, 0x80, 0x80, 0xff})
+	pi := NewPixelImage(img, false)
+
+	rendered := image.NewNRGBA(img.Bounds())
+	rendered.SetNRGBA(0, 0, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	rendered.SetNRGBA(1, 0, color.NRGBA{0x80, 0x80, 0x80, 0xff})
+	stats, heatmap, err := pi.Diff(rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Pixels != 3 || stats.Changed != 1 || stats.MaxError != 0xff {
+		t.Errorf("Unexpected statistics: %s", stats)
+	}
+	// The difference between black and white is 100
+	if math.Abs(stats.MaxDeltaE-100) > 0.01 || math.Abs(stats.MeanDeltaE-100.0/3) > 0.01 {
+		t.Errorf("Unexpected color differences: %s", stats)
+	}
+	if c := heatmap.NRGBAAt(0, 0); c != (color.NRGBA{0xff, 0xff, 0, 0xff}) {
+		t.Errorf("Expected the changed pixel to be yellow, got %v", c)
+	}
+	if c := heatmap.NRGBAAt(1, 0); c.R != c.G || c.G != c.B {
+		t.Errorf("Expected the unchanged pixel to be gray, got %v", c)
+	}
+
+	if _, _, err := pi.Diff(image.NewNRGBA(image.Rect(0, 0, 2, 2))); err == nil {
+		t.Error("Expected an error for a rendered image with a different size")
+	}
+}
+
+func TestDiffRoundTrip(t *testing.T) {
+	glenda, err := ReadPNG("img/glenda.png", false)
+	if err != nil {
+		t.Fatalf("Failed to read PNG file: %v", err)
+	}
+	pi := NewPixelImage(glenda, false)
+	pi.SetColorOptimize(true)
+	pi.coverBoxes()
+	rendered, err := Rasterize(pi.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, _, err := pi.Diff(rendered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Limiting the colors to 4096 changes each color channel by less than 16
+	if stats.Changed == 0 || stats.MaxError >= 16 {
+		t.Errorf("Unexpected statistics for -l: %s", stats)
+	}
+}
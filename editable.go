@@ -0,0 +1,153 @@
+package png2svg
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// inkscapeNamespace is the namespace of the inkscape:label and inkscape:groupmode attributes
+const inkscapeNamespace = "http://www.inkscape.org/namespaces/inkscape"
+
+// SetEditable selects an output mode for editing the SVG image in Inkscape or Illustrator.
+// The rectangles are placed in one <g> layer per color, ordered by area, largest first,
+// where each layer has a stable id, like "color-ff0000", an inkscape:label and a data-color
+// attribute. If regions is true, each connected region of a color is placed in its own
+// <g> layer inside the layer of the color, so that pieces are easy to recolor or delete.
+// The data-color attribute is only written with SVG2Profile, since the other profiles do not
+// have it. This must be set before any pixels are covered.
+func (pi *PixelImage) SetEditable(editable, regions bool) {
+	pi.editable = editable
+	pi.editableRegions = regions
+}
+
+// colorID returns a stable id for the given fill color, like "color-ff0000" for "#ff0000"
+func colorID(fill string) string {
+	return "color-" + strings.TrimPrefix(fill, "#")
+}
+
+// editableAttributes returns the id, inkscape:label and data-color attributes for the given fill color
+func editableAttributes(fill string) string {
+	return fmt.Sprintf(" id=\"%s\" inkscape:label=\"%s\" data-color=\"%s\"", colorID(fill), fill, fill)
+}
+
+// area returns the number of pixels that are covered by the given rectangles
+func area(rects []Rect) int {
+	sum := 0
+	for _, r := range rects {
+		sum += r.W * r.H
+	}
+	return sum
+}
+
+// connectedRegions splits the given rectangles into regions of rectangles that share an edge,
+// ordered by area, largest first. The size of the image is w x h.
+func connectedRegions(rects []Rect, w, h int) [][]Rect {
+	// Find the rectangle that covers each pixel
+	owner := make([]int, w*h)
+	for i := range owner {
+		owner[i] = -1
+	}
+	for i, r := range rects {
+		for y := r.Y; y < r.Y+r.H; y++ {
+			for x := r.X; x < r.X+r.W; x++ {
+				owner[y*w+x] = i
+			}
+		}
+	}
+
+	// Join rectangles that touch the right or bottom edge of another rectangle
+	parent := make([]int, len(rects))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	join := func(i, x, y int) {
+		if x < w && y < h {
+			if j := owner[y*w+x]; j >= 0 {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+	for i, r := range rects {
+		for y := r.Y; y < r.Y+r.H; y++ {
+			join(i, r.X+r.W, y)
+		}
+		for x := r.X; x < r.X+r.W; x++ {
+			join(i, x, r.Y+r.H)
+		}
+	}
+
+	// Collect the regions, in the order of their first rectangle
+	var (
+		regions [][]Rect
+		index   = make(map[int]int)
+	)
+	for i, r := range rects {
+		root := find(i)
+		n, ok := index[root]
+		if !ok {
+			n = len(regions)
+			index[root] = n
+			regions = append(regions, nil)
+		}
+		regions[n] = append(regions[n], r)
+	}
+	sort.SliceStable(regions, func(i, j int) bool {
+		return area(regions[i]) > area(regions[j])
+	})
+	return regions
+}
+
+// writeShapes writes the given rectangles without a fill color, either as <rect> elements or as one <path>
+func writeShapes(buf *bytes.Buffer, rects []Rect, encoding Encoding) {
+	if encoding == PathEncoding {
+		buf.WriteString("<path d=\"")
+		buf.Write(pathData(rects))
+		buf.WriteString("\"/>")
+		return
+	}
+	for _, r := range rects {
+		// Attributes that are 0 are left out, like in the optimize pass of Bytes
+		buf.WriteString("<rect")
+		if r.X != 0 {
+			fmt.Fprintf(buf, " x=\"%d\"", r.X)
+		}
+		if r.Y != 0 {
+			fmt.Fprintf(buf, " y=\"%d\"", r.Y)
+		}
+		fmt.Fprintf(buf, " width=\"%d\" height=\"%d\"/>", r.W, r.H)
+	}
+}
+
+// editableElements returns one <g> layer per fill color, ordered by area, that draws all the
+// recorded rectangles, with one <g> layer per connected region inside, if regions are enabled
+func (pi *PixelImage) editableElements() []byte {
+	var buf bytes.Buffer
+	colors, grouped := groupRectsByFillColor(pi.rects, pi.colorOptimize)
+	sort.SliceStable(colors, func(i, j int) bool {
+		return area(grouped[colors[i]]) > area(grouped[colors[j]])
+	})
+	for _, fill := range colors {
+		fmt.Fprintf(&buf, "<g%s inkscape:groupmode=\"layer\" fill=\"%s\">", editableAttributes(fill), fill)
+		if !pi.editableRegions {
+			writeShapes(&buf, grouped[fill], pi.encoding)
+			buf.WriteString("</g>")
+			continue
+		}
+		for i, region := range connectedRegions(grouped[fill], pi.w, pi.h) {
+			fmt.Fprintf(&buf, "<g id=\"%s-%d\" inkscape:label=\"%s %d\" inkscape:groupmode=\"layer\">", colorID(fill), i+1, fill, i+1)
+			writeShapes(&buf, region, pi.encoding)
+			buf.WriteString("</g>")
+		}
+		buf.WriteString("</g>")
+	}
+	return buf.Bytes()
+}
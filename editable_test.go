@@ -0,0 +1,96 @@
+package png2svg
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEditable(t *testing.T) {
+	glenda, err := ReadPNG("img/glenda.png", false)
+	if err != nil {
+		t.Fatalf("Failed to read PNG file: %v", err)
+	}
+	for _, encoding := range []Encoding{RectEncoding, PathEncoding} {
+		for _, regions := range []bool{false, true} {
+			pi := NewPixelImage(glenda, false)
+			pi.SetEncoding(encoding)
+			pi.SetEditable(true, regions)
+			// The data-color attribute is only a part of SVG 2
+			pi.SetProfile(SVG2Profile)
+			pi.coverBoxes()
+			svgDocument := pi.Bytes()
+			if estimate := pi.EstimateSize(); estimate.Size != len(svgDocument) {
+				t.Errorf("Expected an estimate of %d bytes, got %s", len(svgDocument), estimate)
+			}
+			// Attributes that are 0 are left out, like in the other modes
+			if bytes.Contains(svgDocument, []byte(` x="0"`)) || bytes.Contains(svgDocument, []byte(` y="0"`)) {
+				t.Errorf("Expected x=\"0\" and y=\"0\" to be left out, got %s", svgDocument)
+			}
+
+			root, err := parseNodes(svgDocument)
+			if err != nil {
+				t.Fatalf("Failed to parse the SVG document: %v", err)
+			}
+			areas := make(map[string]int)
+			colors, grouped := groupRectsByFillColor(pi.rects, false)
+			for _, fill := range colors {
+				areas[colorID(fill)] = area(grouped[fill])
+			}
+			ids := make(map[string]bool)
+			lastArea := -1
+			for _, layer := range root.children {
+				attrs := make(map[string]string)
+				for _, attr := range layer.attrs {
+					attrs[qualifiedName(attr.Name)] = attr.Value
+				}
+				id, fill := attrs["id"], attrs["fill"]
+				// Some colors are replaced with names, like "gray" for "#808080", but the ids are kept
+				if _, ok := areas[id]; !ok || layer.name != "g" || attrs["inkscape:label"] != fill || attrs["data-color"] != fill {
+					t.Errorf("Unexpected layer <%s> with %v", layer.name, attrs)
+				}
+				if ids[id] {
+					t.Errorf("The id %q is used more than once", id)
+				}
+				ids[id] = true
+				// The layers are ordered by area, largest first
+				a := areas[id]
+				if lastArea >= 0 && a > lastArea {
+					t.Errorf("Expected the layer %q with area %d to come before the previous one, with area %d", id, a, lastArea)
+				}
+				lastArea = a
+				if regions && (len(layer.children) == 0 || layer.children[0].name != "g") {
+					t.Errorf("Expected the layer %q to contain a <g> per region", id)
+				}
+			}
+
+			// The layers draw the same image
+			img, err := Rasterize(svgDocument)
+			if err != nil {
+				t.Fatalf("Failed to rasterize the SVG document: %v", err)
+			}
+			if differences, err := CompareImages(glenda, img, 0); err != nil || differences != 0 {
+				t.Errorf("Expected no differences with encoding %d and regions %v, got %d (%v)", encoding, regions, differences, err)
+			}
+		}
+	}
+}
+
+func TestConnectedRegions(t *testing.T) {
+	rects := []Rect{
+		{X: 0, Y: 0, W: 2, H: 1},
+		{X: 5, Y: 5, W: 1, H: 1}, // alone
+		{X: 1, Y: 1, W: 1, H: 3}, // below the first rectangle
+		{X: 2, Y: 3, W: 3, H: 1}, // to the right of the third rectangle
+		{X: 3, Y: 0, W: 1, H: 1}, // one pixel to the right of the first rectangle
+	}
+	regions := connectedRegions(rects, 8, 8)
+	if len(regions) != 3 {
+		t.Fatalf("Expected 3 regions, got %d: %v", len(regions), regions)
+	}
+	if len(regions[0]) != 3 || area(regions[0]) != 8 {
+		t.Errorf("Expected the largest region to have 3 rectangles and an area of 8, got %v", regions[0])
+	}
+	if regions[1][0] != rects[1] || regions[2][0] != rects[4] {
+		t.Errorf("Expected regions of the same area to keep their order, got %v", regions)
+	}
+}
@@ -0,0 +1,149 @@
+package png2svg
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+)
+
+// Encoding selects how the rectangles that cover the image are written to the SVG document
+type Encoding int
+
+const (
+	// RectEncoding writes one <rect> element per rectangle, grouped by color (the default)
+	RectEncoding Encoding = iota
+	// PathEncoding writes one <path> element per color, where each rectangle is a subpath
+	// that is drawn with relative path commands
+	PathEncoding
+)
+
+// Rect is a rectangle that covers pixels in the image, together with its fill color
+type Rect struct {
+	X, Y, W, H int
+	Fill       string
+}
+
+// SetEncoding selects how the rectangles are written to the SVG document.
+// This must be set before any pixels are covered.
+func (pi *PixelImage) SetEncoding(encoding Encoding) {
+	pi.encoding = encoding
+}
+
+// Rects returns the rectangles that have been placed so far
+func (pi *PixelImage) Rects() []Rect {
+	return pi.rects
+}
+
+// addRect records a rectangle, and adds a <rect> tag to the SVG document if RectEncoding is used,
+// unless the rectangles are written in layers by the editable mode
+func (pi *PixelImage) addRect(x, y, w, h int, fill string) {
+	pi.rects = append(pi.rects, Rect{x, y, w, h, fill})
+	if pi.encoding == RectEncoding && !pi.editable {
+		pi.svgTag.AddRect(x, y, w, h).Fill(fill)
+	}
+}
+
+// hexColorString returns a color string on the form "#rrggbb"
+func hexColorString(r, g, b int) string {
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
+
+// appendNumbers appends the given numbers to a path command,
+// separated by spaces only where it is needed
+func appendNumbers(buf []byte, numbers ...int) []byte {
+	for i, n := range numbers {
+		if i > 0 && n >= 0 {
+			buf = append(buf, ' ')
+		}
+		buf = strconv.AppendInt(buf, int64(n), 10)
+	}
+	return buf
+}
+
+// pathData returns the "d" attribute for a path that draws all the given rectangles.
+// The first rectangle is placed with an absolute move command, the rest are placed
+// relative to the start of the previous rectangle, which is where "z" leaves the pen.
+func pathData(rects []Rect) []byte {
+	var (
+		buf          []byte
+		lastx, lasty int
+	)
+	for i, r := range rects {
+		if i == 0 {
+			buf = append(buf, 'M')
+			buf = appendNumbers(buf, r.X, r.Y)
+		} else {
+			buf = append(buf, 'm')
+			buf = appendNumbers(buf, r.X-lastx, r.Y-lasty)
+		}
+		buf = append(buf, 'h')
+		buf = appendNumbers(buf, r.W)
+		buf = append(buf, 'v')
+		buf = appendNumbers(buf, r.H)
+		buf = append(buf, 'h')
+		buf = appendNumbers(buf, -r.W)
+		buf = append(buf, 'z')
+		lastx, lasty = r.X, r.Y
+	}
+	return buf
+}
+
+// groupRectsByFillColor groups the given rectangles by their (shortened) fill color.
+// The colors are returned in the order they first appear.
+func groupRectsByFillColor(rects []Rect, colorOptimize bool) ([]string, map[string][]Rect) {
+	var (
+		colors  []string
+		grouped = make(map[string][]Rect)
+		fill    string
+	)
+	for _, r := range rects {
+		if colorOptimize {
+			fill = string(shortenColorLossy([]byte(r.Fill)))
+		} else {
+			fill = string(shortenColorLossless([]byte(r.Fill)))
+		}
+		if _, ok := grouped[fill]; !ok {
+			colors = append(colors, fill)
+		}
+		grouped[fill] = append(grouped[fill], r)
+	}
+	return colors, grouped
+}
+
+// encodeRects returns SVG elements that draw the given rectangles, grouped by fill color.
+// With PathEncoding, there is one <path> element per color. With RectEncoding, there is one
+// <rect> element per rectangle, inside a <g> element per color if there are several rectangles.
+func encodeRects(rects []Rect, encoding Encoding, colorOptimize bool) []byte {
+	var buf bytes.Buffer
+	colors, grouped := groupRectsByFillColor(rects, colorOptimize)
+	for _, fill := range colors {
+		if encoding == PathEncoding {
+			buf.WriteString("<path fill=\"")
+			buf.WriteString(fill)
+			buf.WriteString("\" d=\"")
+			buf.Write(pathData(grouped[fill]))
+			buf.WriteString("\"/>")
+			continue
+		}
+		group := len(grouped[fill]) > 1
+		if group {
+			buf.WriteString("<g fill=\"" + fill + "\">")
+		}
+		for _, r := range grouped[fill] {
+			fmt.Fprintf(&buf, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\"", r.X, r.Y, r.W, r.H)
+			if !group {
+				buf.WriteString(" fill=\"" + fill + "\"")
+			}
+			buf.WriteString("/>")
+		}
+		if group {
+			buf.WriteString("</g>")
+		}
+	}
+	return buf.Bytes()
+}
+
+// pathElements returns one <path> element per fill color, that draws all the recorded rectangles
+func (pi *PixelImage) pathElements() []byte {
+	return encodeRects(pi.rects, PathEncoding, pi.colorOptimize)
+}
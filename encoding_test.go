@@ -0,0 +1,97 @@
+package png2svg
+
+import (
+	"image/color"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+var (
+	pathElementRegexp = regexp.MustCompile(`<path fill="([^"]+)" d="([^"]+)"/>`)
+	subpathRegexp     = regexp.MustCompile(`([Mm])(-?\d+) ?(-?\d+)h(-?\d+)v(-?\d+)h(-?\d+)z`)
+)
+
+// parseHexColor parses colors on the form #rgb or #rrggbb, or color names from colorReplacements
+func parseHexColor(t *testing.T, s string) color.NRGBA {
+	for k, v := range colorReplacements {
+		if string(v) == s {
+			s = k
+		}
+	}
+	if len(s) == 4 {
+		s = string([]byte{'#', s[1], s[1], s[2], s[2], s[3], s[3]})
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if len(s) != 7 || err != nil {
+		t.Fatalf("Could not parse color %q", s)
+	}
+	return color.NRGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}
+}
+
+func TestPathEncodingRoundTrip(t *testing.T) {
+	for _, filename := range []string{"testdata/jumpline16.png", "img/glenda.png"} {
+		img, err := ReadPNG(filename, false)
+		if err != nil {
+			t.Fatalf("Failed to read PNG file: %v", err)
+		}
+		pi := NewPixelImage(img, false)
+		pi.SetEncoding(PathEncoding)
+		pi.coverBoxes()
+		svgDocument := pi.Bytes()
+
+		// Draw the rectangles in the path elements. Boxes may overlap, but only with the same color.
+		drawn := make(map[[2]int]color.NRGBA)
+		for _, element := range pathElementRegexp.FindAllSubmatch(svgDocument, -1) {
+			fill := parseHexColor(t, string(element[1]))
+			var x, y int
+			for _, subpath := range subpathRegexp.FindAllSubmatch(element[2], -1) {
+				var n [5]int
+				for i := range n {
+					n[i], _ = strconv.Atoi(string(subpath[i+2]))
+				}
+				if subpath[1][0] == 'M' {
+					x, y = n[0], n[1]
+				} else {
+					x, y = x+n[0], y+n[1]
+				}
+				if n[4] != -n[2] {
+					t.Fatalf("%s: subpath %q is not a rectangle", filename, subpath[0])
+				}
+				for py := y; py < y+n[3]; py++ {
+					for px := x; px < x+n[2]; px++ {
+						if previous, ok := drawn[[2]int{px, py}]; ok && previous != fill {
+							t.Fatalf("%s: pixel (%d,%d) is drawn with both %v and %v", filename, px, py, previous, fill)
+						}
+						drawn[[2]int{px, py}] = fill
+					}
+				}
+			}
+		}
+
+		// Check that all pixels have the color of the original image
+		for y := 0; y < pi.h; y++ {
+			for x := 0; x < pi.w; x++ {
+				r, g, b, a := pi.At2(x, y)
+				got, ok := drawn[[2]int{x, y}]
+				if a == 0 {
+					if ok {
+						t.Errorf("%s: transparent pixel (%d,%d) is drawn", filename, x, y)
+					}
+					continue
+				}
+				if want := (color.NRGBA{uint8(r), uint8(g), uint8(b), 0xff}); got != want {
+					t.Fatalf("%s: pixel (%d,%d) is %v, want %v", filename, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestPathData(t *testing.T) {
+	rects := []Rect{{0, 0, 16, 1, "#fff"}, {13, 1, 3, 2, "#fff"}, {2, 0, 1, 1, "#fff"}}
+	const want = "M0 0h16v1h-16zm13 1h3v2h-3zm-11-1h1v1h-1z"
+	if got := string(pathData(rects)); got != want {
+		t.Errorf("pathData(%v) = %s, want %s", rects, got, want)
+	}
+}
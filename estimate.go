@@ -0,0 +1,136 @@
+package png2svg
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/xyproto/tinysvg"
+)
+
+// SizeEstimate contains the predicted size of the SVG document, for each encoding
+type SizeEstimate struct {
+	Rects  int // the number of rectangles
+	Colors int // the number of fill colors
+	Rect   int // the predicted size in bytes with RectEncoding
+	Path   int // the predicted size in bytes with PathEncoding
+	Size   int // the predicted size in bytes with the selected encoding
+}
+
+// String returns a short summary of the estimate
+func (se *SizeEstimate) String() string {
+	return fmt.Sprintf("%d rectangles in %d colors, about %d bytes (%d with rect encoding, %d with path encoding)",
+		se.Rects, se.Colors, se.Size, se.Rect, se.Path)
+}
+
+// digits returns the number of characters that are needed to write n
+func digits(n int) int {
+	count := 1
+	if n < 0 {
+		count++
+		n = -n
+	}
+	for ; n >= 10; n /= 10 {
+		count++
+	}
+	return count
+}
+
+// pathNumbers returns the length of the numbers of a path command, as written by appendNumbers
+func pathNumbers(numbers ...int) int {
+	length := 0
+	for i, n := range numbers {
+		if i > 0 && n >= 0 {
+			length++
+		}
+		length += digits(n)
+	}
+	return length
+}
+
+// EstimateSize predicts the size of the SVG document that Bytes returns, by counting the
+// rectangles per color and adding up the sizes of the elements, for both encodings. This is
+// much faster than rendering the SVG document, and can be used to refuse writing documents that
+// are too large. The estimate is exact, except for some colors that are replaced with names.
+func (pi *PixelImage) EstimateSize() *SizeEstimate {
+	colors, grouped := groupRectsByFillColor(pi.rects, pi.colorOptimize)
+	se := &SizeEstimate{Rects: len(pi.rects), Colors: len(colors)}
+
+	// Find the size of everything but the rectangles, by rendering an empty document with the same settings
+	empty := &PixelImage{
+		w:               pi.w,
+		h:               pi.h,
+		colorOptimize:   pi.colorOptimize,
+		displayWidth:    pi.displayWidth,
+		displayHeight:   pi.displayHeight,
+		stretch:         pi.stretch,
+		crispEdges:      pi.crispEdges,
+		compact:         pi.compact,
+		encoding:        pi.encoding,
+		xlink:           pi.xlink,
+		metadata:        pi.metadata,
+		editable:        pi.editable,
+		editableRegions: pi.editableRegions,
+		profile:         pi.profile,
+	}
+	empty.document, empty.svgTag = tinysvg.NewTinySVG(pi.w, pi.h)
+	empty.defs.Write(pi.defs.Bytes())
+	empty.prelude.Write(pi.prelude.Bytes())
+	empty.elements.Write(pi.elements.Bytes())
+	document := empty.Bytes()
+	overhead := len(document)
+	if len(pi.rects) > 0 && !bytes.HasSuffix(document, []byte("</svg>")) {
+		// The <svg> tag of the empty document has no children, and is rendered as <svg .../>
+		overhead += len("></svg>") - len("/>")
+	}
+	se.Rect, se.Path = overhead, overhead
+
+	for _, key := range colors {
+		rects, fill := grouped[key], key
+		if name, ok := pi.profile.colorReplacements()[fill]; ok {
+			fill = string(name)
+		}
+
+		// <path fill="..." d="..."/>
+		se.Path += len(`<path fill="" d=""/>`) + len(fill)
+		lastx, lasty := 0, 0
+		for i, r := range rects {
+			if i == 0 {
+				se.Path += 1 + pathNumbers(r.X, r.Y)
+			} else {
+				se.Path += 1 + pathNumbers(r.X-lastx, r.Y-lasty)
+			}
+			se.Path += 1 + pathNumbers(r.W) + 1 + pathNumbers(r.H) + 1 + pathNumbers(-r.W) + 1
+			lastx, lasty = r.X, r.Y
+		}
+
+		// <g fill="..."><rect .../>...</g>, or a single <rect ... fill="..."/>
+		if len(rects) > 1 {
+			se.Rect += len(`<g fill=""></g>`) + len(fill)
+		} else {
+			se.Rect += len(` fill=""`) + len(fill)
+		}
+		for _, r := range rects {
+			se.Rect += len(`<rect width="" height=""/>`) + digits(r.W) + digits(r.H)
+			// Attributes that are 0 are left out
+			if r.X != 0 {
+				se.Rect += len(` x=""`) + digits(r.X)
+			}
+			if r.Y != 0 {
+				se.Rect += len(` y=""`) + digits(r.Y)
+			}
+		}
+	}
+
+	se.Size = se.Rect
+	if pi.editable {
+		// The layers are rendered, since the regions are not known without finding them
+		editable := pi.applyProfile(pi.editableElements())
+		for k, v := range pi.profile.colorReplacements() {
+			editable = bytes.Replace(editable, []byte(k), v, -1)
+		}
+		se.Size = overhead + len(editable)
+	} else if pi.encoding == PathEncoding {
+		se.Size = se.Path
+	}
+	return se
+}
@@ -0,0 +1,76 @@
+package png2svg
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestEstimateSize(t *testing.T) {
+	glenda, err := ReadPNG("img/glenda.png", false)
+	if err != nil {
+		t.Fatalf("Failed to read PNG file: %v", err)
+	}
+	for _, encoding := range []Encoding{RectEncoding, PathEncoding} {
+		for _, optimize := range []bool{false, true} {
+			pi := NewPixelImage(glenda, false)
+			pi.SetEncoding(encoding)
+			pi.SetColorOptimize(optimize)
+			pi.SetCompact(optimize)
+			if optimize {
+				if _, err := pi.DrawBackground(color.NRGBA{0xff, 0xff, 0xff, 0xff}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			pi.coverBoxes()
+			estimate := pi.EstimateSize()
+			if n := len(pi.Bytes()); estimate.Size != n {
+				t.Errorf("Expected an estimate of %d bytes with encoding %d and optimize %v, got %s", n, encoding, optimize, estimate)
+			}
+			if estimate.Rects != len(pi.rects) || estimate.Path >= estimate.Rect {
+				t.Errorf("Unexpected estimate: %s", estimate)
+			}
+		}
+	}
+}
+
+func TestOutputSize(t *testing.T) {
+	glenda, err := ReadPNG("img/glenda.png", false)
+	if err != nil {
+		t.Fatalf("Failed to read PNG file: %v", err)
+	}
+	pi := NewPixelImage(glenda, false)
+	pi.SetMetadata(&Metadata{Title: "Glenda", Author: "Renée French"})
+	pi.coverBoxes()
+	svgDocument := pi.Bytes()
+
+	// Estimating the size does not change the image
+	defs, prelude, elements := pi.defs.Len(), pi.prelude.Len(), pi.elements.Len()
+	if size, err := pi.OutputSize("glenda.svg"); err != nil || size != len(svgDocument) {
+		t.Errorf("Expected %d bytes, got %d (%v)", len(svgDocument), size, err)
+	}
+	if pi.defs.Len() != defs || pi.prelude.Len() != prelude || pi.elements.Len() != elements {
+		t.Error("Expected the estimate to not change the image")
+	}
+
+	// Compressed and embedded SVG images are measured after compressing or embedding them
+	size, err := pi.OutputSize("glenda.svgz")
+	if err != nil || size <= 0 || size*3 > len(svgDocument) {
+		t.Errorf("Expected the compressed size to be much smaller than %d bytes, got %d (%v)", len(svgDocument), size, err)
+	}
+	pi.SetFormat(CSSFormat, "glenda")
+	css, err := Embed(svgDocument, CSSFormat, "glenda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, err := pi.OutputSize("glenda.css"); err != nil || size != len(css) {
+		t.Errorf("Expected %d bytes, got %d (%v)", len(css), size, err)
+	}
+}
+
+func TestDigits(t *testing.T) {
+	for n, expected := range map[int]int{0: 1, 9: 1, 10: 2, -1: 2, -100: 4, 12345: 5} {
+		if d := digits(n); d != expected {
+			t.Errorf("Expected %d to have %d digits, got %d", n, expected, d)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package png2svg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Format selects how the SVG document is written by WriteSVG
+type Format int
+
+const (
+	// SVGFormat writes the SVG document as it is (the default)
+	SVGFormat Format = iota
+	// DataURIFormat writes a data:image/svg+xml URI
+	DataURIFormat
+	// CSSFormat writes a CSS rule that uses the data URI as the background image
+	CSSFormat
+	// ImageFormat writes an HTML <img> tag that uses the data URI as the source
+	ImageFormat
+	// InlineFormat writes an <svg> tag that can be placed directly in an HTML document
+	InlineFormat
+	// JSXFormat writes a React component that returns the <svg> tag
+	JSXFormat
+	// GoFormat writes a Go source file with the SVG document in a []byte variable
+	GoFormat
+	// GoFuncFormat writes a Go source file with a function that draws the SVG image with tinysvg
+	GoFuncFormat
+)
+
+// SetFormat selects how the SVG document is written by WriteSVG. The name is used for the CSS
+// class, the React component or the Go package and identifiers, and is typically the filename
+// of the PNG image, without the extension.
+func (pi *PixelImage) SetFormat(format Format, name string) {
+	pi.format = format
+	pi.formatName = name
+}
+
+// words splits a name, like a filename, into words of letters and digits
+func words(name string) []string {
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// className returns a CSS class name for the given name, like "my-icon" for "My Icon.png"
+func className(name string) string {
+	class := strings.ToLower(strings.Join(words(name), "-"))
+	if class == "" {
+		return "svg"
+	}
+	if r, _ := utf8.DecodeRuneInString(class); unicode.IsDigit(r) {
+		return "svg-" + class
+	}
+	return class
+}
+
+// componentName returns a React component name for the given name, like "MyIcon" for "my-icon.png".
+// The name starts with "Svg" if the first letter has no upper case, since React components must.
+func componentName(name string) string {
+	var sb strings.Builder
+	for _, word := range words(name) {
+		r, size := utf8.DecodeRuneInString(word)
+		sb.WriteRune(unicode.ToUpper(r))
+		sb.WriteString(word[size:])
+	}
+	component := sb.String()
+	if r, _ := utf8.DecodeRuneInString(component); !unicode.IsUpper(r) {
+		return "Svg" + component
+	}
+	return component
+}
+
+// stripDeclaration removes the XML declaration, which is not needed when the SVG document is embedded
+func stripDeclaration(svgDocument []byte) []byte {
+	return bytes.TrimSpace(bytes.TrimPrefix(bytes.TrimSpace(svgDocument), []byte(xmlDeclaration)))
+}
+
+// DataURI returns the SVG document as a data:image/svg+xml URI, with as little escaping as possible.
+// Double quotes are replaced with single quotes, so that the URI can be placed in double quotes in
+// CSS and HTML, and only the characters that must be escaped are percent-encoded. "&" is also encoded,
+// since HTML decodes entities like "&amp;" in attribute values. If base64 is shorter,
+// which can happen for documents with many special characters, base64 is used instead.
+func DataURI(svgDocument []byte) string {
+	svgDocument = stripDeclaration(svgDocument)
+	// Single quotes can only be used for the attributes if there are no single quotes in the text
+	swapQuotes := !bytes.ContainsRune(svgDocument, '\'')
+	var sb strings.Builder
+	sb.WriteString("data:image/svg+xml,")
+	for _, c := range svgDocument {
+		switch {
+		case c == '"' && swapQuotes:
+			sb.WriteByte('\'')
+		case c == '"' || c == '%' || c == '#' || c == '&' || c == '<' || c == '>' || c == '{' || c == '}' ||
+			c == '|' || c == '\\' || c == '^' || c == '`' || c < ' ' || c > '~':
+			fmt.Fprintf(&sb, "%%%02X", c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	encoded := base64.StdEncoding.EncodeToString(svgDocument)
+	if len("data:image/svg+xml;base64,")+len(encoded) < sb.Len() {
+		return "data:image/svg+xml;base64," + encoded
+	}
+	return sb.String()
+}
+
+// jsxAttribute matches attribute names with "-" or ":", which are written in camel case in JSX
+var jsxAttribute = regexp.MustCompile(` ([a-z]+)[-:]([a-z]+)(?:-([a-z]+))?=`)
+
+// metadataElement matches the <metadata> element, with the RDF metadata in other namespaces
+var metadataElement = regexp.MustCompile(`(?s)<metadata>.*?</metadata>`)
+
+// escapeJSXText escapes "{" and "}" in the text between the tags, where they would start and end
+// JavaScript expressions in JSX. Attribute values in quotes are left as they are.
+func escapeJSXText(svgDocument []byte) []byte {
+	var (
+		buf          bytes.Buffer
+		inTag, quote bool
+	)
+	for _, b := range svgDocument {
+		switch {
+		case inTag && b == '"':
+			quote = !quote
+		case quote:
+		case b == '<':
+			inTag = true
+		case inTag && b == '>':
+			inTag = false
+		case !inTag && b == '{':
+			buf.WriteString("{'{'}")
+			continue
+		case !inTag && b == '}':
+			buf.WriteString("{'}'}")
+			continue
+		}
+		buf.WriteByte(b)
+	}
+	return buf.Bytes()
+}
+
+// jsx converts the attributes of an SVG document to JSX, like "xlink:href" to "xlinkHref"
+// and "fill-opacity" to "fillOpacity". The aria-* and data-* attributes are kept as they are.
+// The <metadata> element is removed, since React does not support the namespaced RDF tags in it,
+// and "{" and "}" in the text are escaped.
+func jsx(svgDocument []byte) []byte {
+	svgDocument = escapeJSXText(metadataElement.ReplaceAll(svgDocument, nil))
+	return jsxAttribute.ReplaceAllFunc(svgDocument, func(match []byte) []byte {
+		parts := jsxAttribute.FindSubmatch(match)
+		name := string(parts[1])
+		if name == "aria" || name == "data" {
+			return match
+		}
+		for _, part := range parts[2:] {
+			if len(part) > 0 {
+				name += strings.ToUpper(string(part[:1])) + string(part[1:])
+			}
+		}
+		return []byte(" " + name + "=")
+	})
+}
+
+// Embed returns the SVG document in the given format, for embedding it in CSS, HTML, a React
+// application or a Go program. The name is used for the CSS class, the alt text of the <img> tag,
+// the React component and the Go package and identifiers, and is typically the filename of the
+// PNG image, without the extension.
+func Embed(svgDocument []byte, format Format, name string) ([]byte, error) {
+	switch format {
+	case SVGFormat:
+		return svgDocument, nil
+	case DataURIFormat:
+		return []byte(DataURI(svgDocument) + "\n"), nil
+	case CSSFormat:
+		return []byte(fmt.Sprintf(".%s {\n\tbackground-image: url(\"%s\");\n}\n", className(name), DataURI(svgDocument))), nil
+	case ImageFormat:
+		alt := strings.Join(words(name), " ")
+		return []byte(fmt.Sprintf("<img src=\"%s\" alt=\"%s\">\n", DataURI(svgDocument), alt)), nil
+	case InlineFormat:
+		return append(stripDeclaration(svgDocument), '\n'), nil
+	case JSXFormat:
+		svgTag := jsx(stripDeclaration(svgDocument))
+		// Let the props override the attributes of the <svg> tag
+		if i := bytes.IndexByte(svgTag, '>'); i > 0 {
+			end := i
+			if svgTag[i-1] == '/' {
+				end--
+			}
+			svgTag = append(svgTag[:end:end], append([]byte(" {...props}"), svgTag[end:]...)...)
+		}
+		return []byte(fmt.Sprintf("export default function %s(props) {\n\treturn (\n\t\t%s\n\t);\n}\n", componentName(name), svgTag)), nil
+	case GoFormat, GoFuncFormat:
+		return GoSource(svgDocument, name, format == GoFuncFormat)
+	}
+	return nil, fmt.Errorf("unknown format %d", format)
+}
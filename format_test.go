@@ -0,0 +1,125 @@
+package png2svg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html"
+	"image"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestDataURI(t *testing.T) {
+	glenda, err := ReadPNG("img/glenda.png", false)
+	if err != nil {
+		t.Fatalf("Failed to read PNG file: %v", err)
+	}
+	pi := NewPixelImage(glenda, false)
+	pi.coverBoxes()
+	uri := DataURI(pi.Bytes())
+	if strings.ContainsAny(uri, "\"#<>") {
+		t.Error("The data URI contains characters that should be escaped")
+	}
+	svgDocument, err := url.PathUnescape(strings.TrimPrefix(uri, "data:image/svg+xml,"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := Rasterize([]byte(svgDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := CompareImages(glenda, img, 0); n > 0 {
+		t.Errorf("%d pixels differ after decoding the data URI", n)
+	}
+
+	// HTML decodes entities in the src attribute of an <img> tag, so "&" must be encoded
+	pi = NewPixelImage(glenda, false)
+	pi.SetMetadata(&Metadata{Title: "Tom & Jerry"})
+	pi.coverBoxes()
+	imgTag, err := Embed(pi.Bytes(), ImageFormat, "tom-and-jerry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := strings.TrimPrefix(string(imgTag), `<img src="`)
+	src = html.UnescapeString(src[:strings.IndexByte(src, '"')])
+	if strings.Contains(src, "&") {
+		t.Errorf("Expected the data URI to not contain \"&\", got %s", src)
+	}
+	svgDocument, err = url.PathUnescape(strings.TrimPrefix(src, "data:image/svg+xml,"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img, err = Rasterize([]byte(svgDocument)); err != nil {
+		t.Fatalf("The SVG document in the <img> tag is invalid: %v", err)
+	}
+	if n, _ := CompareImages(glenda, img, 0); n > 0 {
+		t.Errorf("%d pixels differ after decoding the <img> tag", n)
+	}
+
+	// Documents where most characters must be escaped are shorter with base64
+	special := []byte(`<svg xmlns="http://www.w3.org/2000/svg"><title>` + strings.Repeat("æøå", 50) + `</title></svg>`)
+	uri = DataURI(special)
+	encoded := strings.TrimPrefix(uri, "data:image/svg+xml;base64,")
+	if encoded == uri {
+		t.Fatalf("Expected base64 to be used, got %s", uri)
+	}
+	if decoded, _ := base64.StdEncoding.DecodeString(encoded); !bytes.Equal(decoded, special) {
+		t.Error("The base64 encoded data URI does not contain the SVG document")
+	}
+}
+
+func TestNames(t *testing.T) {
+	for name, expected := range map[string][2]string{
+		"glenda":       {"glenda", "Glenda"},
+		"my_icon (2)":  {"my-icon-2", "MyIcon2"},
+		"16x16-arrows": {"svg-16x16-arrows", "Svg16x16Arrows"},
+		"":             {"svg", "Svg"},
+		"école":        {"école", "École"},
+		"图标":           {"图标", "Svg图标"},
+	} {
+		if class := className(name); class != expected[0] {
+			t.Errorf("Expected the class name %q for %q, got %q", expected[0], name, class)
+		}
+		if component := componentName(name); component != expected[1] || !utf8.ValidString(component) {
+			t.Errorf("Expected the component name %q for %q, got %q", expected[1], name, component)
+		}
+	}
+}
+
+func TestEmbed(t *testing.T) {
+	svgDocument := []byte(xmlDeclaration + `<svg xmlns:xlink="http://www.w3.org/1999/xlink" shape-rendering="crispEdges"><use xlink:href="#t0" fill-opacity="0.5"/></svg>`)
+	output, err := Embed(svgDocument, JSXFormat, "my-icon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"function MyIcon(props)", ` xmlnsXlink="`, ` shapeRendering="`, ` xlinkHref="`, ` fillOpacity="`, `"crispEdges" {...props}>`} {
+		if !bytes.Contains(output, []byte(s)) {
+			t.Errorf("Expected the React component to contain %q, got %s", s, output)
+		}
+	}
+
+	// Braces in the text are escaped, and the RDF metadata is left out
+	pi := NewPixelImage(image.NewNRGBA(image.Rect(0, 0, 2, 2)), false)
+	pi.SetMetadata(&Metadata{Title: "Logo {beta}", Author: "Bob", ID: "{id}-"})
+	output, err = Embed(pi.Bytes(), JSXFormat, "logo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title := `<title id="{id}-title">Logo {'{'}beta{'}'}</title>`; !bytes.Contains(output, []byte(title)) {
+		t.Errorf("Expected the React component to contain %q, got %s", title, output)
+	}
+	for _, s := range []string{"<metadata", "rdf:", "cc:", "Logo {beta}"} {
+		if bytes.Contains(output, []byte(s)) {
+			t.Errorf("Expected the React component to not contain %q, got %s", s, output)
+		}
+	}
+
+	if output, _ = Embed(svgDocument, InlineFormat, ""); bytes.Contains(output, []byte("<?xml")) {
+		t.Error("Expected the XML declaration to be removed from the inline <svg> tag")
+	}
+	if output, _ = Embed(svgDocument, CSSFormat, "arrow"); !bytes.HasPrefix(output, []byte(".arrow {\n\tbackground-image: url(\"data:image/svg+xml,")) {
+		t.Errorf("Unexpected CSS rule: %s", output)
+	}
+}
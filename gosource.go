@@ -0,0 +1,220 @@
+package png2svg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// goPackageName returns a Go package name for the given name, like "myicon" for "my-icon.png"
+func goPackageName(name string) string {
+	pkg := strings.ToLower(strings.Join(words(name), ""))
+	// A package named main would need a main function, so it is prefixed like the keywords
+	if r, _ := utf8.DecodeRuneInString(pkg); pkg == "" || unicode.IsDigit(r) || token.IsKeyword(pkg) || pkg == "main" {
+		return "svg" + pkg
+	}
+	return pkg
+}
+
+// goString returns a Go string literal, as a raw string literal if possible
+func goString(s string) string {
+	if strings.ContainsAny(s, "`\r") {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// xmlNode is an element in an SVG document, with the attributes in the same order as in the document
+type xmlNode struct {
+	name     string
+	attrs    []xml.Attr
+	text     []byte
+	children []*xmlNode
+}
+
+// qualifiedName returns the name with the namespace prefix, like "xlink:href"
+func qualifiedName(name xml.Name) string {
+	if name.Space != "" {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
+
+// parseNodes parses an SVG document into a tree of nodes, and returns the root node
+func parseNodes(svgDocument []byte) (*xmlNode, error) {
+	var (
+		root    *xmlNode
+		stack   []*xmlNode
+		decoder = xml.NewDecoder(bytes.NewReader(svgDocument))
+	)
+	for {
+		// RawToken keeps the namespace prefixes as they are
+		t, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: qualifiedName(t.Name), attrs: t.Copy().Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root == nil {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("unexpected </%s>", qualifiedName(t.Name))
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				n := stack[len(stack)-1]
+				n.text = append(n.text, t...)
+			}
+		}
+	}
+	if root == nil || root.name != "svg" {
+		return nil, fmt.Errorf("not an SVG document")
+	}
+	return root, nil
+}
+
+// escapeXML escapes text for use in XML content or attribute values
+func escapeXML(s []byte) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, s)
+	return buf.String()
+}
+
+// goDrawer writes Go code that draws the nodes of an SVG document with tinysvg
+type goDrawer struct {
+	buf   bytes.Buffer
+	count int // the number of variables
+}
+
+// rectNumbers contains the positions of the numeric attributes of a <rect>, in the arguments to AddRect
+var rectNumbers = map[string]int{"x": 0, "y": 1, "width": 2, "height": 3}
+
+// simpleRect returns the x, y, width, height and fill of a <rect> that can be drawn with
+// AddRect and Fill, where the fill may be empty
+func simpleRect(n *xmlNode) (numbers [4]int, fill string, ok bool) {
+	if n.name != "rect" || len(n.children) > 0 || len(bytes.TrimSpace(n.text)) > 0 {
+		return numbers, "", false
+	}
+	for _, attr := range n.attrs {
+		if attr.Name.Space == "" && attr.Name.Local == "fill" {
+			fill = attr.Value
+			continue
+		}
+		i, found := rectNumbers[attr.Name.Local]
+		if !found || attr.Name.Space != "" {
+			return numbers, "", false
+		}
+		v, err := strconv.Atoi(attr.Value)
+		if err != nil {
+			return numbers, "", false
+		}
+		numbers[i] = v
+	}
+	return numbers, fill, true
+}
+
+// node writes the code that adds the node to the tag with the given variable name
+func (gd *goDrawer) node(parent string, n *xmlNode) {
+	if numbers, fill, ok := simpleRect(n); ok {
+		fmt.Fprintf(&gd.buf, "%s.AddRect(%d, %d, %d, %d)", parent, numbers[0], numbers[1], numbers[2], numbers[3])
+		if fill != "" {
+			fmt.Fprintf(&gd.buf, ".Fill(%q)", escapeXML([]byte(fill)))
+		}
+		gd.buf.WriteByte('\n')
+		return
+	}
+	text := bytes.TrimSpace(n.text)
+	if len(n.attrs) == 0 && len(n.children) == 0 && len(text) == 0 {
+		fmt.Fprintf(&gd.buf, "%s.AddNewTag([]byte(%q))\n", parent, n.name)
+		return
+	}
+	gd.count++
+	tag := fmt.Sprintf("t%d", gd.count)
+	fmt.Fprintf(&gd.buf, "%s := %s.AddNewTag([]byte(%q))\n", tag, parent, n.name)
+	gd.attributes(tag, n.attrs)
+	if len(text) > 0 {
+		fmt.Fprintf(&gd.buf, "%s.AddContent([]byte(%s))\n", tag, goString(escapeXML(text)))
+	}
+	for _, child := range n.children {
+		gd.node(tag, child)
+	}
+}
+
+// attributes writes the code that adds the attributes to the tag with the given variable name
+func (gd *goDrawer) attributes(tag string, attrs []xml.Attr) {
+	for _, attr := range attrs {
+		fmt.Fprintf(&gd.buf, "%s.AddAttrib(%q, []byte(%s))\n", tag, qualifiedName(attr.Name), goString(escapeXML([]byte(attr.Value))))
+	}
+}
+
+// GoSource returns a Go source file that contains the SVG document, for embedding it in Go programs,
+// for instance with "go generate". The package name and the identifiers are derived from the name,
+// which is typically the filename of the PNG image, without the extension. If drawFunc is false,
+// the SVG document is placed in a []byte variable. If drawFunc is true, a function that draws the
+// SVG image into a tinysvg.Tag is written instead, together with constants for the size of the image.
+// The function also sets the attributes of the <svg> tag, except for the width, height and viewBox.
+func GoSource(svgDocument []byte, name string, drawFunc bool) ([]byte, error) {
+	var (
+		buf        bytes.Buffer
+		identifier = componentName(name)
+	)
+	fmt.Fprintf(&buf, "// Code generated by %s from %s.png. DO NOT EDIT.\n\n", VersionString, name)
+	fmt.Fprintf(&buf, "package %s\n\n", goPackageName(name))
+	if !drawFunc {
+		fmt.Fprintf(&buf, "// %s is the SVG image that was converted from %s.png\n", identifier, name)
+		fmt.Fprintf(&buf, "var %s = []byte(%s)\n", identifier, goString(string(stripDeclaration(svgDocument))))
+		return format.Source(buf.Bytes())
+	}
+
+	root, err := parseNodes(svgDocument)
+	if err != nil {
+		return nil, err
+	}
+	var w, h float64
+	for _, attr := range root.attrs {
+		if attr.Name.Local == "viewBox" {
+			var x, y float64
+			if _, err := fmt.Sscan(strings.ReplaceAll(attr.Value, ",", " "), &x, &y, &w, &h); err != nil {
+				return nil, fmt.Errorf("invalid viewBox %q", attr.Value)
+			}
+		}
+	}
+	fmt.Fprintf(&buf, "import \"github.com/xyproto/tinysvg\"\n\n")
+	fmt.Fprintf(&buf, "// %sWidth and %sHeight are the size of the image, for tinysvg.NewTinySVG\n", identifier, identifier)
+	fmt.Fprintf(&buf, "const (\n%sWidth = %s\n%sHeight = %s\n)\n\n", identifier, formatNumber(w), identifier, formatNumber(h))
+	fmt.Fprintf(&buf, "// Draw%s draws the image that was converted from %s.png into the given tag,\n", identifier, name)
+	fmt.Fprintf(&buf, "// which is typically the <svg> tag that is returned by tinysvg.NewTinySVG\n")
+	fmt.Fprintf(&buf, "func Draw%s(svg *tinysvg.Tag) {\n", identifier)
+	gd := &goDrawer{}
+	// Copy the attributes of the <svg> tag, like the namespaces and shape-rendering, except for the size
+	for _, attr := range root.attrs {
+		switch qualifiedName(attr.Name) {
+		case "width", "height", "viewBox":
+		default:
+			gd.attributes("svg", []xml.Attr{attr})
+		}
+	}
+	for _, child := range root.children {
+		gd.node("svg", child)
+	}
+	buf.Write(gd.buf.Bytes())
+	buf.WriteString("}\n")
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,96 @@
+package png2svg
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestGoSource(t *testing.T) {
+	svgDocument := []byte(xmlDeclaration + `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 4 2">` +
+		`<defs><rect id="t0" width="1" height="1"/></defs><g fill="red"><rect x="1" width="2" height="1"/><use xlink:href="#t0" y="1"/></g>` +
+		`<rect width="1" height="1" fill="#00f"/></svg>`)
+	for _, drawFunc := range []bool{false, true} {
+		source, err := GoSource(svgDocument, "my-icon", drawFunc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parser.ParseFile(token.NewFileSet(), "myicon.go", source, 0); err != nil {
+			t.Fatalf("The generated Go code is invalid: %v\n%s", err, source)
+		}
+		expected := []string{"// Code generated by png2svg", "package myicon\n"}
+		if drawFunc {
+			expected = append(expected, "func DrawMyIcon(svg *tinysvg.Tag) {", "MyIconWidth  = 4", `svg.AddAttrib("xmlns:xlink"`,
+				`t3.AddRect(1, 0, 2, 1)`, `t4.AddAttrib("xlink:href", []byte(`+"`#t0`"+`))`, `svg.AddRect(0, 0, 1, 1).Fill("#00f")`)
+		} else {
+			expected = append(expected, "var MyIcon = []byte(`<svg ")
+		}
+		for _, s := range expected {
+			if !bytes.Contains(source, []byte(s)) {
+				t.Errorf("Expected the Go code to contain %q, got:\n%s", s, source)
+			}
+		}
+	}
+}
+
+func TestGoSourceNames(t *testing.T) {
+	svgDocument := []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 1 1"><rect width="1" height="1" fill="red"/></svg>`)
+	for name, expected := range map[string][]string{
+		"école": {"package école\n", "func DrawÉcole(svg *tinysvg.Tag) {"},
+		"main":  {"package svgmain\n", "func DrawMain(svg *tinysvg.Tag) {"},
+	} {
+		source, err := GoSource(svgDocument, name, true)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err := parser.ParseFile(token.NewFileSet(), "image.go", source, 0); err != nil {
+			t.Fatalf("The generated Go code for %q is invalid: %v\n%s", name, err, source)
+		}
+		for _, s := range expected {
+			if !bytes.Contains(source, []byte(s)) {
+				t.Errorf("Expected the Go code to contain %q, got:\n%s", s, source)
+			}
+		}
+	}
+}
+
+func TestGoSourceRootAttributes(t *testing.T) {
+	glenda, err := ReadPNG("img/glenda.png", false)
+	if err != nil {
+		t.Fatalf("Failed to read PNG file: %v", err)
+	}
+	pi := NewPixelImage(glenda, false)
+	pi.SetCrispEdges(true)
+	pi.SetProfile(SVG2Profile)
+	pi.SetMetadata(&Metadata{Title: "Glenda"})
+	if err := pi.SetPixelSize(2, 3); err != nil {
+		t.Fatal(err)
+	}
+	pi.coverBoxes()
+	source, err := GoSource(pi.Bytes(), "glenda", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`svg.AddAttrib("shape-rendering", []byte(` + "`crispEdges`" + `))`,
+		`svg.AddAttrib("preserveAspectRatio", []byte(` + "`none`" + `))`,
+		`svg.AddAttrib("role", []byte(` + "`img`" + `))`,
+		`svg.AddAttrib("aria-labelledby", []byte(` + "`title`" + `))`} {
+		if !bytes.Contains(source, []byte(s)) {
+			t.Errorf("Expected the Go code to contain %q, got:\n%s", s, source)
+		}
+	}
+	for _, s := range []string{`"width"`, `"height"`, `"viewBox"`} {
+		if bytes.Contains(source, []byte(`svg.AddAttrib(`+s)) {
+			t.Errorf("Expected the size to only be in the constants, got:\n%s", source)
+		}
+	}
+}
+
+func TestGoPackageName(t *testing.T) {
+	for name, expected := range map[string]string{"glenda": "glenda", "My Icon": "myicon", "16x16": "svg16x16", "func": "svgfunc", "main": "svgmain", "École": "école"} {
+		if pkg := goPackageName(name); pkg != expected {
+			t.Errorf("Expected the package name %q for %q, got %q", expected, name, pkg)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package png2svg
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// GradientStats contains information about the gradients that were found by DetectGradients
+type GradientStats struct {
+	Horizontal int // the number of rectangles where the color changes from left to right
+	Vertical   int // the number of rectangles where the color changes from top to bottom
+	Pixels     int // the number of pixels that are drawn by gradients instead of rectangles
+}
+
+// String returns a short summary of the detected gradients
+func (gs *GradientStats) String() string {
+	return fmt.Sprintf("%d horizontal and %d vertical gradients, covering %d pixels",
+		gs.Horizontal, gs.Vertical, gs.Pixels)
+}
+
+// gradient is a rectangle where the color changes linearly along one axis
+type gradient struct {
+	x, y, w, h int
+	vertical   bool
+	from, to   [3]int // the colors of the first and the last pixel
+}
+
+// fillString returns the given color as a hex color string, shortened if possible,
+// or shortened in a lossy way if colorOptimize is enabled
+func (pi *PixelImage) fillString(r, g, b int) string {
+	fill := []byte(hexColorString(r, g, b))
+	if pi.colorOptimize {
+		return string(shortenColorLossy(fill))
+	}
+	return string(shortenColorLossless(fill))
+}
+
+// closeColors checks if all color channels of two pixels differ by at most the given tolerance
+func closeColors(p, q *Pixel, tolerance int) bool {
+	return abs(float64(p.r-q.r)) <= float64(tolerance) &&
+		abs(float64(p.g-q.g)) <= float64(tolerance) &&
+		abs(float64(p.b-q.b)) <= float64(tolerance)
+}
+
+// gradientPixel returns the pixel at the position u along the gradient and v across it,
+// or nil if the pixel is outside of the image, covered or not fully opaque
+func (pi *PixelImage) gradientPixel(u, v int, vertical bool) *Pixel {
+	x, y := u, v
+	if vertical {
+		x, y = v, u
+	}
+	if x < 0 || y < 0 || x >= pi.w || y >= pi.h {
+		return nil
+	}
+	p := pi.pixels[y*pi.w+x]
+	if p.covered || p.a != 0xff {
+		return nil
+	}
+	return p
+}
+
+// across returns how many pixels, starting at (u, v), have the same color
+// within the tolerance, going across the gradient
+func (pi *PixelImage) across(u, v int, vertical bool, tolerance int) int {
+	first := pi.gradientPixel(u, v, vertical)
+	if first == nil {
+		return 0
+	}
+	n := 1
+	for p := pi.gradientPixel(u, v+n, vertical); p != nil && closeColors(first, p, tolerance); p = pi.gradientPixel(u, v+n, vertical) {
+		n++
+	}
+	return n
+}
+
+// slopes keeps track of the range of slopes, for each color channel, that a line through the color
+// of the first pixel can have, while still being within the tolerance of the following pixels
+type slopes struct {
+	first    [3]float64
+	min, max [3]float64
+}
+
+// newSlopes returns a range of slopes that allows any slope, starting at the given pixel
+func newSlopes(p *Pixel) *slopes {
+	s := &slopes{first: [3]float64{float64(p.r), float64(p.g), float64(p.b)}}
+	for c := range s.min {
+		s.min[c], s.max[c] = math.Inf(-1), math.Inf(1)
+	}
+	return s
+}
+
+// add narrows down the range of slopes, so that the line is within the tolerance of the given
+// pixel, which is the given distance from the first pixel. Returns false if that is not possible.
+func (s *slopes) add(p *Pixel, distance int, tolerance int) bool {
+	d, t := float64(distance), float64(tolerance)
+	for c, v := range [3]float64{float64(p.r), float64(p.g), float64(p.b)} {
+		s.min[c] = math.Max(s.min[c], (v-t-s.first[c])/d)
+		s.max[c] = math.Min(s.max[c], (v+t-s.first[c])/d)
+		if s.min[c] > s.max[c] {
+			return false
+		}
+	}
+	return true
+}
+
+// at returns the color of the line with the slopes in the middle of the ranges,
+// at the given distance from the first pixel
+func (s *slopes) at(distance int) [3]int {
+	var rgb [3]int
+	for c := range rgb {
+		v := s.first[c] + (s.min[c]+s.max[c])/2*float64(distance)
+		rgb[c] = int(math.Round(math.Max(0, math.Min(0xff, v))))
+	}
+	return rgb
+}
+
+// findGradient finds the largest gradient that starts at the given pixel, in the given direction.
+// The gradient must be at least minLength pixels long, and contain at least 3 distinct colors.
+// To avoid searching through areas with a single color, the gradient must start with a change of color.
+func (pi *PixelImage) findGradient(x, y int, vertical bool, tolerance, minLength int) (gradient, bool) {
+	u0, v0 := x, y
+	if vertical {
+		u0, v0 = y, x
+	}
+	first, second := pi.gradientPixel(u0, v0, vertical), pi.gradientPixel(u0+1, v0, vertical)
+	if first == nil || second == nil || closeColors(first, second, 0) {
+		return gradient{}, false
+	}
+	var (
+		best      gradient
+		bestArea  int
+		thickness = pi.across(u0, v0, vertical, tolerance)
+		colors    = 1
+		line      = newSlopes(first)
+	)
+extend:
+	for u := u0 + 1; ; u++ {
+		t := pi.across(u, v0, vertical, tolerance)
+		if t == 0 {
+			break
+		}
+		if t < thickness {
+			thickness = t
+		}
+		// Every pixel across the gradient must be within the tolerance of the line, not only the first
+		for v := v0; v < v0+thickness; v++ {
+			if !line.add(pi.gradientPixel(u, v, vertical), u-u0, tolerance) {
+				break extend
+			}
+		}
+		p := pi.gradientPixel(u, v0, vertical)
+		if !closeColors(p, pi.gradientPixel(u-1, v0, vertical), 0) {
+			colors++
+		}
+		length := u - u0 + 1
+		if length < minLength || colors < 3 || length*thickness <= bestArea {
+			continue
+		}
+		g := gradient{x, y, length, thickness, vertical, [3]int{first.r, first.g, first.b}, line.at(length - 1)}
+		if vertical {
+			g.w, g.h = thickness, length
+		}
+		if pi.fitsGradient(g, tolerance) {
+			best, bestArea = g, length*thickness
+		}
+	}
+	return best, bestArea > 0
+}
+
+// fitsGradient checks that all the pixels of the gradient are within the tolerance of the colors
+// that the gradient is drawn with, which are interpolated between the first and the last pixel
+func (pi *PixelImage) fitsGradient(g gradient, tolerance int) bool {
+	length := g.w
+	if g.vertical {
+		length = g.h
+	}
+	for y := g.y; y < g.y+g.h; y++ {
+		for x := g.x; x < g.x+g.w; x++ {
+			d := x - g.x
+			if g.vertical {
+				d = y - g.y
+			}
+			p := pi.pixels[y*pi.w+x]
+			for c, v := range [3]int{p.r, p.g, p.b} {
+				expected := float64(g.from[c]) + float64(g.to[c]-g.from[c])*float64(d)/float64(length-1)
+				if abs(math.Round(expected)-float64(v)) > float64(tolerance) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// DetectGradients finds rectangular regions where the color changes linearly from one side
+// to the other, either horizontally or vertically, within the given tolerance for each color
+// channel. Each region is drawn as a single rectangle that is filled with a <linearGradient>
+// from <defs>, and the pixels are marked as covered. Only regions that are at least minLength
+// pixels long are used. This must be done before covering the rest of the image with boxes.
+func (pi *PixelImage) DetectGradients(tolerance, minLength int) (*GradientStats, error) {
+	defer pi.timePhase("gradients")()
+	if tolerance < 0 || tolerance > 0xff {
+		return nil, errors.New("the gradient tolerance must be from 0 to 255")
+	}
+	if minLength < 3 {
+		return nil, errors.New("the minimum gradient length must be at least 3")
+	}
+	stats := &GradientStats{}
+	for y := 0; y < pi.h; y++ {
+		for x := 0; x < pi.w; x++ {
+			if pi.pixels[y*pi.w+x].covered {
+				continue
+			}
+			horizontal, foundHorizontal := pi.findGradient(x, y, false, tolerance, minLength)
+			vertical, foundVertical := pi.findGradient(x, y, true, tolerance, minLength)
+			if !foundHorizontal && !foundVertical {
+				continue
+			}
+			g := horizontal
+			if !foundHorizontal || (foundVertical && vertical.w*vertical.h > horizontal.w*horizontal.h) {
+				g = vertical
+			}
+			if !pi.drawGradient(g) {
+				continue
+			}
+			if g.vertical {
+				stats.Vertical++
+			} else {
+				stats.Horizontal++
+			}
+			stats.Pixels += g.w * g.h
+		}
+	}
+	if pi.verbose {
+		fmt.Println(stats)
+	}
+	return stats, nil
+}
+
+// drawGradient adds a <linearGradient> to <defs> and a rectangle that is filled with it,
+// and marks the pixels as covered. The stops are placed at the centers of the first and the
+// last pixel. The gradient is only used if it is smaller than drawing the pixels with rectangles.
+// Returns true if the gradient was used.
+func (pi *PixelImage) drawGradient(g gradient) bool {
+	length, direction := g.w, ""
+	if g.vertical {
+		length, direction = g.h, ` x2="0" y2="1"`
+	}
+	offset := math.Round(10000*0.5/float64(length)) / 10000
+	id := fmt.Sprintf("g%d", pi.gradientCount)
+	definition := fmt.Sprintf("<linearGradient id=\"%s\"%s><stop offset=\"%s\" stop-color=\"%s\"/><stop offset=\"%s\" stop-color=\"%s\"/></linearGradient>",
+		id, direction, formatNumber(offset), pi.fillString(g.from[0], g.from[1], g.from[2]), formatNumber(1-offset), pi.fillString(g.to[0], g.to[1], g.to[2]))
+	rect := fmt.Sprintf("<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"url(#%s)\"/>", g.x, g.y, g.w, g.h, id)
+
+	sub := pi.tileImage(tile{g.x, g.y}, g.w, g.h)
+	sub.coverBoxes()
+	// Boxes from the rest of the image often extend into the area anyway, so require a good margin
+	if 2*(len(definition)+len(rect)) >= len(encodeRects(sub.rects, pi.encoding, pi.colorOptimize)) {
+		return false
+	}
+
+	pi.gradientCount++
+	pi.defs.WriteString(definition)
+	pi.prelude.WriteString(rect)
+	for y := g.y; y < g.y+g.h; y++ {
+		for x := g.x; x < g.x+g.w; x++ {
+			pi.pixels[y*pi.w+x].covered = true
+		}
+	}
+	return true
+}
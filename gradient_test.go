@@ -0,0 +1,83 @@
+package png2svg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDetectGradients(t *testing.T) {
+	// A horizontal gradient from black to blue, above a vertical gradient from red to black
+	img := image.NewNRGBA(image.Rect(0, 0, 32, 16))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, color.NRGBA{0, 0, uint8(x * 8), 0xff})
+		}
+	}
+	for y := 8; y < 16; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, color.NRGBA{uint8(0xff - (y-8)*32), 0, 0, 0xff})
+		}
+	}
+
+	pi := NewPixelImage(img, false)
+	stats, err := pi.DetectGradients(2, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Horizontal != 1 || stats.Vertical != 1 || stats.Pixels != 32*16 {
+		t.Errorf("Unexpected gradient statistics: %s", stats)
+	}
+	if !pi.Done(0, 0) {
+		t.Error("Expected all pixels to be covered by the gradients")
+	}
+	svgDocument := pi.Bytes()
+	if n := bytes.Count(svgDocument, []byte("<linearGradient ")); n != 2 {
+		t.Errorf("Expected 2 <linearGradient> elements, got %d", n)
+	}
+	if !bytes.Contains(svgDocument, []byte(`stop-color="#0000f8"`)) {
+		t.Errorf("Expected the horizontal gradient to end with the color of the last pixel:\n%s", svgDocument)
+	}
+	rendered, err := Rasterize(svgDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if differences, err := CompareImages(img, rendered, 2); err != nil || differences != 0 {
+		t.Errorf("Expected the gradients to be drawn within the tolerance, got %d differences (%v)", differences, err)
+	}
+
+	// A noisy gradient, where every pixel must be drawn within the tolerance, not only the first row
+	noisy := image.NewNRGBA(image.Rect(0, 0, 32, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 32; x++ {
+			// The first row is 2 lighter than the rest in every other column
+			noise := 2 * (x % 2)
+			if y > 0 {
+				noise -= 2
+			}
+			noisy.Set(x, y, color.NRGBA{0x80, uint8(0x40 + noise), uint8(x*6 + 0x20 + noise), 0xff})
+		}
+	}
+	pi = NewPixelImage(noisy, false)
+	if stats, err = pi.DetectGradients(2, 4); err != nil || stats.Pixels == 0 {
+		t.Fatalf("Expected a gradient, got %v, %v", stats, err)
+	}
+	pi.coverBoxes()
+	rendered, err = Rasterize(pi.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if differences, err := CompareImages(noisy, rendered, 2); err != nil || differences != 0 {
+		t.Errorf("Expected all pixels to be within the tolerance, got %d differences (%v)", differences, err)
+	}
+
+	// An image with a single color has no gradients
+	pi = NewPixelImage(image.NewNRGBA(image.Rect(0, 0, 8, 8)), false)
+	if stats, err := pi.DetectGradients(2, 4); err != nil || stats.Pixels != 0 {
+		t.Errorf("Expected no gradients, got %v, %v", stats, err)
+	}
+	if _, err := pi.DetectGradients(-1, 4); err == nil {
+		t.Error("Expected an error for a negative tolerance")
+	}
+}
@@ -0,0 +1,185 @@
+package png2svg
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// maxGridPitch is the largest grid pitch, in pixels, that DetectGrid looks for
+const maxGridPitch = 64
+
+// minGridScore is the fraction of the runs of pixels that must fit a grid pitch for it to be used
+const minGridScore = 0.9
+
+// Grid describes the grid of blocks of pixels in a screenshot of upscaled pixel art,
+// where each logical pixel is a block of PitchX x PitchY pixels. The first whole block
+// starts at (PhaseX, PhaseY), and there may be partial blocks before it.
+type Grid struct {
+	PitchX, PitchY int
+	PhaseX, PhaseY int
+	// Confidence is from 0 to 1, where 1 means that all the changes of color in the image
+	// are on the grid lines, and all the runs of pixels with the same color fit the grid
+	Confidence float64
+}
+
+// String returns a short description of the grid
+func (g *Grid) String() string {
+	return fmt.Sprintf("%dx%d grid at (%d, %d), with %.0f%% confidence", g.PitchX, g.PitchY, g.PhaseX, g.PhaseY, g.Confidence*100)
+}
+
+// samePixel checks if two pixels have the same alpha and the same color, within the tolerance
+func samePixel(p, q *Pixel, tolerance int) bool {
+	return p.a == q.a && (p.a == 0 || closeColors(p, q, tolerance))
+}
+
+// axisHistograms returns, for one axis, a histogram of the lengths of the runs of pixels with the
+// same color that do not touch the edges of the image, and the number of color changes at each
+// position along the axis. If vertical is true, the runs go down the columns instead of along the rows.
+func (pi *PixelImage) axisHistograms(vertical bool, tolerance int) (runs map[int]int, changes []int) {
+	length, lines := pi.w, pi.h
+	if vertical {
+		length, lines = pi.h, pi.w
+	}
+	at := func(line, i int) *Pixel {
+		if vertical {
+			return pi.pixels[i*pi.w+line]
+		}
+		return pi.pixels[line*pi.w+i]
+	}
+	runs = make(map[int]int)
+	changes = make([]int, length)
+	for line := 0; line < lines; line++ {
+		start := 0
+		for i := 1; i < length; i++ {
+			if samePixel(at(line, i-1), at(line, i), tolerance) {
+				continue
+			}
+			changes[i]++
+			if start > 0 {
+				runs[i-start]++
+			}
+			start = i
+		}
+	}
+	return runs, changes
+}
+
+// detectPitch finds the largest pitch that most of the runs are a multiple of, together with the
+// offset, from 0 to pitch-1, where most of the color changes happen. Both must fit for a pitch to
+// be used. Returns a pitch of 0 if there are no runs.
+func detectPitch(runs map[int]int, changes []int) (pitch, phase int, confidence float64) {
+	total, allChanges := 0, 0
+	for _, n := range runs {
+		total += n
+	}
+	for _, n := range changes {
+		allChanges += n
+	}
+	if total == 0 || allChanges == 0 {
+		return 0, 0, 0
+	}
+	pitch, confidence = 1, 1
+	for p := 2; p <= maxGridPitch && p <= len(changes)/2; p++ {
+		fitting := 0
+		for length, n := range runs {
+			if length%p == 0 {
+				fitting += n
+			}
+		}
+		runScore := float64(fitting) / float64(total)
+		if runScore < minGridScore {
+			continue
+		}
+
+		// Search all the offsets, for the one where most of the color changes are on the grid lines
+		counts := make([]int, p)
+		for i, n := range changes {
+			counts[i%p] += n
+		}
+		offset := 0
+		for o, n := range counts {
+			if n > counts[offset] {
+				offset = o
+			}
+		}
+		if changeScore := float64(counts[offset]) / float64(allChanges); changeScore >= minGridScore {
+			pitch, phase, confidence = p, offset, runScore*changeScore
+		}
+	}
+	return pitch, phase, confidence
+}
+
+// DetectGrid finds the grid of blocks in a screenshot of upscaled pixel art, by looking at
+// histograms of the lengths of the runs of pixels with the same color, within the given
+// tolerance for each color channel. The grid pitch can be different horizontally and vertically.
+// If no grid is found, the pitch is 1.
+func (pi *PixelImage) DetectGrid(tolerance int) *Grid {
+	hruns, hchanges := pi.axisHistograms(false, tolerance)
+	vruns, vchanges := pi.axisHistograms(true, tolerance)
+	pitchX, phaseX, confidenceX := detectPitch(hruns, hchanges)
+	pitchY, phaseY, confidenceY := detectPitch(vruns, vchanges)
+
+	// If the color never changes along one of the axes, assume that the blocks are square
+	switch {
+	case pitchX == 0 && pitchY == 0:
+		return &Grid{1, 1, 0, 0, 0}
+	case pitchX == 0:
+		pitchX, phaseX, confidenceX = pitchY, 0, confidenceY
+	case pitchY == 0:
+		pitchY, phaseY, confidenceY = pitchX, 0, confidenceX
+	}
+	g := &Grid{pitchX, pitchY, phaseX, phaseY, min(confidenceX, confidenceY)}
+	if pi.verbose {
+		fmt.Println("Detected a", g)
+	}
+	return g
+}
+
+// cellBounds returns the start positions of the cells along an axis of the given length,
+// including partial cells at the start and at the end, followed by the length
+func cellBounds(length, pitch, phase int) []int {
+	var bounds []int
+	if phase > 0 {
+		bounds = append(bounds, 0)
+	}
+	for i := phase; i < length; i += pitch {
+		bounds = append(bounds, i)
+	}
+	return append(bounds, length)
+}
+
+// NativeImage reconstructs the image at its native resolution, where each block of the grid
+// becomes one pixel, with the most common color in the block. The image can then be converted
+// with NewPixelImage, so that the blocks are covered as single pixels, and shown at the original
+// size with SetPixelSize.
+func (pi *PixelImage) NativeImage(g *Grid) *image.NRGBA {
+	xs := cellBounds(pi.w, g.PitchX, g.PhaseX)
+	ys := cellBounds(pi.h, g.PitchY, g.PhaseY)
+	native := image.NewNRGBA(image.Rect(0, 0, len(xs)-1, len(ys)-1))
+	count := make(map[color.NRGBA]int)
+	for cy := 0; cy+1 < len(ys); cy++ {
+		for cx := 0; cx+1 < len(xs); cx++ {
+			clear(count)
+			var (
+				common color.NRGBA
+				most   int
+			)
+			for y := ys[cy]; y < ys[cy+1]; y++ {
+				for x := xs[cx]; x < xs[cx+1]; x++ {
+					p := pi.pixels[y*pi.w+x]
+					c := color.NRGBA{uint8(p.r), uint8(p.g), uint8(p.b), uint8(p.a)}
+					if p.a == 0 {
+						c = color.NRGBA{}
+					}
+					count[c]++
+					if count[c] > most || (count[c] == most && rgb(c) < rgb(common)) {
+						common, most = c, count[c]
+					}
+				}
+			}
+			native.SetNRGBA(cx, cy, common)
+		}
+	}
+	return native
+}
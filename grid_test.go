@@ -0,0 +1,58 @@
+package png2svg
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDetectGrid(t *testing.T) {
+	glenda, err := ReadPNG("img/glenda.png", false)
+	if err != nil {
+		t.Fatalf("Failed to read PNG file: %v", err)
+	}
+
+	// Upscale to blocks of pitchX x pitchY pixels, where the first whole block starts at
+	// (phaseX, phaseY), and add some noise to the first pixel of every other block
+	for _, tc := range []struct{ pitchX, pitchY, phaseX, phaseY int }{
+		{3, 2, 2, 1},
+		{4, 4, 3, 3}, // the largest offset for the pitch
+		{2, 5, 1, 4},
+	} {
+		cell := func(i, pitch, phase int) int {
+			if i < phase {
+				return 0
+			}
+			return 1 + (i-phase)/pitch
+		}
+		w, h := tc.phaseX+63*tc.pitchX, tc.phaseY+63*tc.pitchY
+		screenshot := image.NewNRGBA(image.Rect(0, 0, w, h))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				cx, cy := cell(x, tc.pitchX, tc.phaseX), cell(y, tc.pitchY, tc.phaseY)
+				c := color.NRGBAModel.Convert(glenda.At(cx, cy)).(color.NRGBA)
+				if (x-tc.phaseX)%tc.pitchX == 0 && (y-tc.phaseY)%tc.pitchY == 0 && (cx+cy)%2 == 0 && c.R > 2 {
+					c.R -= 2
+				}
+				screenshot.SetNRGBA(x, y, c)
+			}
+		}
+
+		pi := NewPixelImage(screenshot, false)
+		grid := pi.DetectGrid(8)
+		if grid.PitchX != tc.pitchX || grid.PitchY != tc.pitchY || grid.PhaseX != tc.phaseX || grid.PhaseY != tc.phaseY {
+			t.Fatalf("Expected a %dx%d grid at (%d, %d), got a %s", tc.pitchX, tc.pitchY, tc.phaseX, tc.phaseY, grid)
+		}
+		if grid.Confidence < 0.9 {
+			t.Errorf("Expected a high confidence, got a %s", grid)
+		}
+		if native := pi.NativeImage(grid); !sameImage(native, glenda) {
+			t.Errorf("Expected the native image of the %s to be the same as the original image", grid)
+		}
+	}
+
+	// An image that is not upscaled has a pitch of 1
+	if grid := NewPixelImage(glenda, false).DetectGrid(0); grid.PitchX != 1 || grid.PitchY != 1 {
+		t.Errorf("Expected a 1x1 grid, got a %s", grid)
+	}
+}
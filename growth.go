@@ -0,0 +1,142 @@
+package png2svg
+
+import (
+	"fmt"
+	"sort"
+)
+
+// GrowthStrategy decides in which order a box is expanded in the four directions
+type GrowthStrategy interface {
+	// Grow expands the box until it can't expand any more.
+	// Returns true if the box was expanded at least once.
+	Grow(pi *PixelImage, bo *Box) bool
+}
+
+// expandFunc is one of the ExpandRight, ExpandDown, ExpandLeft or ExpandUp methods
+type expandFunc func(pi *PixelImage, bo *Box) bool
+
+// prioritized expands a box in the first direction that is possible, starting over
+// with the first direction after every successful expansion
+type prioritized []expandFunc
+
+// Grow expands the box, trying the directions in order
+func (directions prioritized) Grow(pi *PixelImage, bo *Box) (expanded bool) {
+	for directions.growOnce(pi, bo) {
+		expanded = true
+	}
+	return
+}
+
+// growOnce expands the box once, in the first direction that is possible
+func (directions prioritized) growOnce(pi *PixelImage, bo *Box) bool {
+	for _, expand := range directions {
+		if expand(pi, bo) {
+			return true
+		}
+	}
+	return false
+}
+
+// alternating expands a box one step in each direction in turn,
+// skipping the directions that are not possible
+type alternating []expandFunc
+
+// Grow expands the box, taking turns between the directions
+func (directions alternating) Grow(pi *PixelImage, bo *Box) (expanded bool) {
+	for {
+		grew := false
+		for _, expand := range directions {
+			if expand(pi, bo) {
+				grew = true
+			}
+		}
+		if !grew {
+			return
+		}
+		expanded = true
+	}
+}
+
+// squareFirst expands a box along the shortest side first, to keep it as square as possible
+type squareFirst struct{}
+
+// Grow expands the box, widening it if it is narrower than it is tall, and heightening it otherwise
+func (squareFirst) Grow(pi *PixelImage, bo *Box) (expanded bool) {
+	var (
+		widen    = prioritized{(*PixelImage).ExpandRight, (*PixelImage).ExpandLeft, (*PixelImage).ExpandDown, (*PixelImage).ExpandUp}
+		heighten = prioritized{(*PixelImage).ExpandDown, (*PixelImage).ExpandUp, (*PixelImage).ExpandRight, (*PixelImage).ExpandLeft}
+	)
+	for {
+		directions := heighten
+		if bo.w <= bo.h {
+			directions = widen
+		}
+		if !directions.growOnce(pi, bo) {
+			return
+		}
+		expanded = true
+	}
+}
+
+// largestArea tries several growth strategies and keeps the largest box
+type largestArea []GrowthStrategy
+
+// Grow expands copies of the box with each strategy, and keeps the one with the largest area
+func (strategies largestArea) Grow(pi *PixelImage, bo *Box) bool {
+	best := *bo
+	for _, strategy := range strategies {
+		candidate := *bo
+		strategy.Grow(pi, &candidate)
+		if candidate.w*candidate.h > best.w*best.h {
+			best = candidate
+		}
+	}
+	expanded := best.w*best.h > bo.w*bo.h
+	*bo = best
+	return expanded
+}
+
+var (
+	// RightThenDown expands a box to the right as long as possible, then downwards.
+	// This is the default strategy.
+	RightThenDown GrowthStrategy = prioritized{(*PixelImage).ExpandRight, (*PixelImage).ExpandDown}
+
+	// DownThenRight expands a box downwards as long as possible, then to the right
+	DownThenRight GrowthStrategy = prioritized{(*PixelImage).ExpandDown, (*PixelImage).ExpandRight}
+
+	// Alternating expands a box one step to the right, down, left and up in turn
+	Alternating GrowthStrategy = alternating{(*PixelImage).ExpandRight, (*PixelImage).ExpandDown, (*PixelImage).ExpandLeft, (*PixelImage).ExpandUp}
+
+	// SquareFirst expands a box along the shortest side first, in all four directions
+	SquareFirst GrowthStrategy = squareFirst{}
+
+	// LargestArea tries all the other strategies, and keeps the largest box
+	LargestArea GrowthStrategy = largestArea{RightThenDown, DownThenRight, Alternating, SquareFirst}
+
+	// GrowthStrategies contains all the growth strategies, by name
+	GrowthStrategies = map[string]GrowthStrategy{
+		"right-down":  RightThenDown,
+		"down-right":  DownThenRight,
+		"alternating": Alternating,
+		"square":      SquareFirst,
+		"largest":     LargestArea,
+	}
+)
+
+// GrowthStrategyByName returns the growth strategy with the given name, like "right-down"
+func GrowthStrategyByName(name string) (GrowthStrategy, error) {
+	if strategy, ok := GrowthStrategies[name]; ok {
+		return strategy, nil
+	}
+	names := make([]string, 0, len(GrowthStrategies))
+	for k := range GrowthStrategies {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown growth strategy %q, must be one of %v", name, names)
+}
+
+// SetGrowthStrategy selects how boxes are expanded by Expand
+func (pi *PixelImage) SetGrowthStrategy(strategy GrowthStrategy) {
+	pi.growth = strategy
+}
@@ -0,0 +1,92 @@
+package png2svg
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestExpandToEdges(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 3; x++ {
+			img.Set(x, y, color.NRGBA{0xff, 0, 0, 0xff})
+		}
+	}
+	pi := NewPixelImage(img, false)
+	box := pi.CreateBox(1, 1)
+	if !pi.ExpandLeft(box) || !pi.ExpandUp(box) {
+		t.Fatal("Expected the box to expand to column 0 and row 0")
+	}
+	if box.x != 0 || box.y != 0 || box.w != 2 || box.h != 2 {
+		t.Errorf("Unexpected box after expanding left and up: %+v", *box)
+	}
+	if pi.ExpandLeft(box) || pi.ExpandUp(box) {
+		t.Error("Expected the box to not expand beyond the image")
+	}
+}
+
+func TestGrowthStrategies(t *testing.T) {
+	img, err := ReadPNG("img/glenda.png", false)
+	if err != nil {
+		t.Fatalf("Failed to read PNG file: %v", err)
+	}
+	for name, strategy := range GrowthStrategies {
+		pi := NewPixelImage(img, false)
+		pi.SetGrowthStrategy(strategy)
+		pi.coverBoxes()
+
+		// Check that every rectangle only covers pixels of its own color
+		covered := make([]bool, pi.w*pi.h)
+		for _, r := range pi.Rects() {
+			for y := r.Y; y < r.Y+r.H; y++ {
+				for x := r.X; x < r.X+r.W; x++ {
+					if cr, cg, cb, _ := pi.At2(x, y); hexColorString(cr, cg, cb) != r.Fill {
+						t.Fatalf("%s: rectangle %v covers pixel (%d,%d) of another color", name, r, x, y)
+					}
+					covered[y*pi.w+x] = true
+				}
+			}
+		}
+		for i, p := range pi.pixels {
+			if p.a != 0 && !covered[i] {
+				t.Fatalf("%s: pixel (%d,%d) is not covered by any rectangle", name, p.x, p.y)
+			}
+		}
+	}
+}
+
+// BenchmarkGrowthStrategies reports how many rectangles each growth strategy needs for the images in img/.
+// PathEncoding is used, so that only the rectangles are recorded and no tags are created.
+func BenchmarkGrowthStrategies(b *testing.B) {
+	filenames, err := filepath.Glob("img/*.png")
+	if err != nil {
+		b.Fatal(err)
+	}
+	names := make([]string, 0, len(GrowthStrategies))
+	for name := range GrowthStrategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, filename := range filenames {
+		img, err := ReadPNG(filename, false)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, name := range names {
+			b.Run(filepath.Base(filename)+"/"+name, func(b *testing.B) {
+				var rects int
+				for i := 0; i < b.N; i++ {
+					pi := NewPixelImage(img, false)
+					pi.SetEncoding(PathEncoding)
+					pi.SetGrowthStrategy(GrowthStrategies[name])
+					pi.coverBoxes()
+					rects = len(pi.Rects())
+				}
+				b.ReportMetric(float64(rects), "rects")
+			})
+		}
+	}
+}
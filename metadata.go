@@ -0,0 +1,80 @@
+package png2svg
+
+import (
+	"bytes"
+)
+
+// Metadata contains a title and a description for screen readers, and metadata about where the
+// SVG image came from. Empty fields are left out of the SVG document.
+type Metadata struct {
+	Title       string // placed in a <title> element, and used as the accessible name
+	Description string // placed in a <desc> element
+	ID          string // a prefix for the ids of <title> and <desc>, for images that are placed in the same HTML document
+	Source      string // the filename of the PNG image
+	Author      string // the name of the author
+	License     string // the URL of the license, like https://creativecommons.org/licenses/by/4.0/
+	Generator   bool   // include the name and version of png2svg
+}
+
+// SetMetadata adds a title and a description to the SVG document, together with role="img" and
+// aria-labelledby on the <svg> tag, so that the image is announced by screen readers, and adds
+// an RDF <metadata> element with the source filename, the author, the license and the generator.
+// The aria-labelledby attribute is only written with SVG2Profile, and role="img" is not written
+// with SVG11Profile, since the other profiles do not have them.
+func (pi *PixelImage) SetMetadata(m *Metadata) {
+	pi.metadata = m
+}
+
+// accessible checks if the SVG document has a title or a description
+func (m *Metadata) accessible() bool {
+	return m != nil && (m.Title != "" || m.Description != "")
+}
+
+// labelledBy returns the value of the aria-labelledby attribute, which refers to the ids
+// of the <title> and <desc> elements
+func (m *Metadata) labelledBy() []byte {
+	var ids [][]byte
+	if m.Title != "" {
+		ids = append(ids, []byte(m.ID+"title"))
+	}
+	if m.Description != "" {
+		ids = append(ids, []byte(m.ID+"desc"))
+	}
+	return bytes.Join(ids, []byte{' '})
+}
+
+// element writes an element with the given text, if the text is not empty
+func element(buf *bytes.Buffer, start, end, text string) {
+	if text != "" {
+		buf.WriteString(start)
+		buf.WriteString(escapeXML([]byte(text)))
+		buf.WriteString(end)
+	}
+}
+
+// elements returns the <title>, <desc> and <metadata> elements, which are placed first in the SVG document
+func (m *Metadata) elements() []byte {
+	var buf bytes.Buffer
+	if m == nil {
+		return nil
+	}
+	element(&buf, `<title id="`+escapeXML([]byte(m.ID))+`title">`, "</title>", m.Title)
+	element(&buf, `<desc id="`+escapeXML([]byte(m.ID))+`desc">`, "</desc>", m.Description)
+	if m.Source == "" && m.Author == "" && m.License == "" && !m.Generator {
+		return buf.Bytes()
+	}
+	buf.WriteString(`<metadata><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:cc="http://creativecommons.org/ns#">`)
+	buf.WriteString(`<cc:Work rdf:about=""><dc:format>image/svg+xml</dc:format>`)
+	element(&buf, "<dc:title>", "</dc:title>", m.Title)
+	element(&buf, "<dc:description>", "</dc:description>", m.Description)
+	element(&buf, "<dc:source>", "</dc:source>", m.Source)
+	element(&buf, "<dc:creator><cc:Agent><dc:title>", "</dc:title></cc:Agent></dc:creator>", m.Author)
+	if m.Generator {
+		element(&buf, "<dc:contributor><cc:Agent><dc:title>", "</dc:title></cc:Agent></dc:contributor>", VersionString)
+	}
+	if m.License != "" {
+		buf.WriteString(`<cc:license rdf:resource="` + escapeXML([]byte(m.License)) + `"/>`)
+	}
+	buf.WriteString("</cc:Work></rdf:RDF></metadata>")
+	return buf.Bytes()
+}
@@ -0,0 +1,80 @@
+package png2svg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	glenda, err := ReadPNG("img/glenda.png", false)
+	if err != nil {
+		t.Fatalf("Failed to read PNG file: %v", err)
+	}
+	pi := NewPixelImage(glenda, false)
+	pi.SetProfile(SVG2Profile)
+	pi.SetMetadata(&Metadata{
+		Title:       "Glenda & friends",
+		Description: "The Plan 9 bunny",
+		ID:          "glenda-",
+		Source:      "glenda.png",
+		Author:      "Renée French",
+		License:     "https://creativecommons.org/licenses/by/4.0/",
+		Generator:   true,
+	})
+	pi.coverBoxes()
+	svgDocument := pi.Bytes()
+
+	root, err := parseNodes(svgDocument)
+	if err != nil {
+		t.Fatalf("Failed to parse the SVG document: %v", err)
+	}
+	attrs := make(map[string]string)
+	for _, attr := range root.attrs {
+		attrs[qualifiedName(attr.Name)] = attr.Value
+	}
+	if attrs["role"] != "img" || attrs["aria-labelledby"] != "glenda-title glenda-desc" {
+		t.Errorf("Expected role=\"img\" and aria-labelledby, got %v", attrs)
+	}
+	if len(root.children) < 3 {
+		t.Fatalf("Expected at least 3 children, got %d", len(root.children))
+	}
+	for i, expected := range []string{"title", "desc", "metadata"} {
+		if name := root.children[i].name; name != expected {
+			t.Errorf("Expected child %d to be <%s>, got <%s>", i, expected, name)
+		}
+	}
+	if text := string(root.children[0].text); text != "Glenda & friends" {
+		t.Errorf("Unexpected title %q", text)
+	}
+	for _, expected := range []string{"<dc:source>glenda.png</dc:source>", "Renée French", VersionString,
+		`<cc:license rdf:resource="https://creativecommons.org/licenses/by/4.0/"/>`} {
+		if !strings.Contains(string(svgDocument), expected) {
+			t.Errorf("Expected the metadata to contain %q", expected)
+		}
+	}
+
+	// The metadata is not drawn
+	img, err := Rasterize(svgDocument)
+	if err != nil {
+		t.Fatalf("Failed to rasterize the SVG document: %v", err)
+	}
+	if differences, err := CompareImages(glenda, img, 0); err != nil || differences != 0 {
+		t.Errorf("Expected no differences, got %d (%v)", differences, err)
+	}
+
+	// A description without a title
+	pi = NewPixelImage(glenda, false)
+	pi.SetProfile(SVG2Profile)
+	pi.SetMetadata(&Metadata{Description: "Only a description"})
+	svgDocument = pi.Bytes()
+	if !strings.Contains(string(svgDocument), `aria-labelledby="desc"`) || strings.Contains(string(svgDocument), "<metadata>") {
+		t.Errorf("Unexpected SVG document: %s", svgDocument)
+	}
+
+	// SVG Tiny 1.2 has role="img", but no aria-* attributes
+	pi.SetProfile(TinyProfile)
+	svgDocument = pi.Bytes()
+	if !strings.Contains(string(svgDocument), `role="img"`) || strings.Contains(string(svgDocument), "aria-") {
+		t.Errorf("Unexpected SVG document: %s", svgDocument)
+	}
+}
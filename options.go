@@ -0,0 +1,107 @@
+package png2svg
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+// ImageOptions contains settings for how the pixels of an image are interpreted
+// by NewPixelImageWithOptions, before anything is drawn
+type ImageOptions struct {
+	// TransparentColors are key colors that are treated as fully transparent,
+	// like magenta (#ff00ff) in many old sprites
+	TransparentColors []color.NRGBA
+	// TransparentTolerance is how much each color channel may differ from a key color,
+	// for the pixel to still be treated as transparent
+	TransparentTolerance int
+	// AlphaThreshold is the lowest alpha value, from 0 to 255, where a pixel is not transparent.
+	// Pixels with an alpha value of 0 are always transparent.
+	AlphaThreshold int
+	// Binarize makes all pixels that are not transparent fully opaque,
+	// so that anti-aliased edges can be merged with the pixels next to them
+	Binarize bool
+	// Matte is a color that all pixels that are not fully opaque are composited over,
+	// so that the image becomes fully opaque. Transparent pixels get the matte color.
+	// If Matte is nil, the pixels are not composited.
+	Matte *color.NRGBA
+}
+
+// NewImageOptions returns ImageOptions with default values, where the pixels are used as they are
+func NewImageOptions() *ImageOptions {
+	return &ImageOptions{}
+}
+
+// validate checks that the options have valid values
+func (o *ImageOptions) validate() error {
+	if o.TransparentTolerance < 0 || o.TransparentTolerance > 0xff {
+		return errors.New("the transparent tolerance must be from 0 to 255")
+	}
+	if o.AlphaThreshold < 0 || o.AlphaThreshold > 0xff {
+		return errors.New("the alpha threshold must be from 0 to 255")
+	}
+	return nil
+}
+
+// transparent checks if the color is close enough to one of the key colors to be treated as transparent
+func (o *ImageOptions) transparent(c color.NRGBA) bool {
+	t := o.TransparentTolerance
+	for _, key := range o.TransparentColors {
+		if absDiff(c.R, key.R) <= t && absDiff(c.G, key.G) <= t && absDiff(c.B, key.B) <= t {
+			return true
+		}
+	}
+	return false
+}
+
+// absDiff returns the absolute difference between two color channels
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// unpremultiply converts a color with premultiplied alpha to a color with non-premultiplied alpha.
+// The color channels are rounded to the nearest value, and are limited to 255, in case they are
+// larger than the alpha value, which color.NRGBAModel does not handle.
+func unpremultiply(c color.RGBA) color.NRGBA {
+	switch c.A {
+	case 0:
+		return color.NRGBA{}
+	case 0xff:
+		return color.NRGBA{c.R, c.G, c.B, c.A}
+	}
+	channel := func(v uint8) uint8 {
+		return uint8(min(0xff, (int(v)*0xff+int(c.A)/2)/int(c.A)))
+	}
+	return color.NRGBA{channel(c.R), channel(c.G), channel(c.B), c.A}
+}
+
+// pixelColor returns the color of the pixel at (x, y) in the given image, after applying the options
+func (o *ImageOptions) pixelColor(img image.Image, x, y int) color.NRGBA {
+	var c color.NRGBA
+	if rgba, ok := img.(*image.RGBA); ok {
+		c = unpremultiply(rgba.RGBAAt(x, y))
+	} else {
+		c = color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+	}
+	if c.A == 0 || int(c.A) < o.AlphaThreshold || o.transparent(c) {
+		c = color.NRGBA{}
+	} else if o.Binarize {
+		c.A = 0xff
+	}
+	if o.Matte != nil {
+		return composite(c, *o.Matte)
+	}
+	return c
+}
+
+// composite returns the color c drawn over the opaque color matte
+func composite(c, matte color.NRGBA) color.NRGBA {
+	a := int(c.A)
+	channel := func(v, m uint8) uint8 {
+		return uint8((int(v)*a + int(m)*(0xff-a) + 0x7f) / 0xff)
+	}
+	return color.NRGBA{channel(c.R, matte.R), channel(c.G, matte.G), channel(c.B, matte.B), 0xff}
+}
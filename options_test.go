@@ -0,0 +1,121 @@
+package png2svg
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestTransparentColors(t *testing.T) {
+	// A magenta sprite sheet, with a slightly different magenta in one corner
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.Set(x, y, color.NRGBA{0xff, 0, 0xff, 0xff})
+		}
+	}
+	img.Set(0, 0, color.NRGBA{0xfe, 0, 0xfd, 0xff})
+	img.Set(1, 1, color.NRGBA{0, 0xff, 0, 0xff})
+
+	magenta := color.NRGBA{0xff, 0, 0xff, 0xff}
+	pi, err := NewPixelImageWithOptions(img, false, &ImageOptions{TransparentColors: []color.NRGBA{magenta}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pi.Covered(0, 0) || !pi.Covered(0, 1) || pi.Covered(1, 1) {
+		t.Error("Expected only the exact key color to be transparent")
+	}
+
+	pi, err = NewPixelImageWithOptions(img, false, &ImageOptions{TransparentColors: []color.NRGBA{magenta}, TransparentTolerance: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pi.Covered(0, 0) || pi.Covered(1, 1) {
+		t.Error("Expected the key color to be transparent, within the tolerance")
+	}
+	pi.coverBoxes()
+	if rects := pi.Rects(); len(rects) != 1 || rects[0] != (Rect{1, 1, 1, 1, "#00ff00"}) {
+		t.Errorf("Expected only the green pixel to be drawn, got %v", rects)
+	}
+
+	if _, err := NewPixelImageWithOptions(img, false, &ImageOptions{TransparentTolerance: 256}); err == nil {
+		t.Error("Expected an error for a too large tolerance")
+	}
+}
+
+func TestUnpremultiply(t *testing.T) {
+	for _, test := range []struct {
+		premultiplied color.RGBA
+		expected      color.NRGBA
+	}{
+		{color.RGBA{0, 0, 0, 0}, color.NRGBA{0, 0, 0, 0}},
+		{color.RGBA{0xff, 0x80, 0, 0xff}, color.NRGBA{0xff, 0x80, 0, 0xff}},
+		{color.RGBA{0x80, 0x40, 0, 0x80}, color.NRGBA{0xff, 0x80, 0, 0x80}},
+		{color.RGBA{1, 0, 0, 2}, color.NRGBA{0x80, 0, 0, 2}},
+		// Invalid colors, where a channel is larger than alpha, are limited to 255
+		{color.RGBA{0x96, 0, 0, 0x64}, color.NRGBA{0xff, 0, 0, 0x64}},
+	} {
+		if c := unpremultiply(test.premultiplied); c != test.expected {
+			t.Errorf("unpremultiply(%v) = %v, expected %v", test.premultiplied, c, test.expected)
+		}
+	}
+}
+
+func TestAlphaThreshold(t *testing.T) {
+	// A premultiplied red sprite with anti-aliased edges on the left and right side
+	img := image.NewRGBA(image.Rect(0, 0, 6, 2))
+	for y := 0; y < 2; y++ {
+		img.SetRGBA(0, y, color.RGBA{0x20, 0, 0, 0x20})
+		img.SetRGBA(1, y, color.RGBA{0xc0, 0, 0, 0xc0})
+		for x := 2; x < 4; x++ {
+			img.SetRGBA(x, y, color.RGBA{0xff, 0, 0, 0xff})
+		}
+		img.SetRGBA(4, y, color.RGBA{0x80, 0, 0, 0x80})
+		img.SetRGBA(5, y, color.RGBA{0x10, 0, 0, 0x10})
+	}
+
+	// By default, every edge pixel is drawn, and only pixels with the same alpha are merged
+	pi := NewPixelImage(img, false)
+	pi.coverBoxes()
+	if n := len(pi.Rects()); n != 5 {
+		t.Errorf("Expected 5 rectangles, got %d: %v", n, pi.Rects())
+	}
+	for _, p := range pi.pixels {
+		if p.r != 0xff || p.g != 0 || p.b != 0 {
+			t.Fatalf("Expected all pixels to be red after unpremultiplying, got %+v", *p)
+		}
+	}
+
+	// With a threshold, the faint edges are transparent, and with binarization the rest is merged
+	pi, err := NewPixelImageWithOptions(img, false, &ImageOptions{AlphaThreshold: 0x80, Binarize: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pi.Covered(0, 0) || pi.Covered(1, 0) || pi.Covered(4, 0) || !pi.Covered(5, 0) {
+		t.Error("Expected the pixels with an alpha value below the threshold to be transparent")
+	}
+	pi.coverBoxes()
+	if rects := pi.Rects(); len(rects) != 1 || rects[0] != (Rect{1, 0, 4, 2, "#ff0000"}) {
+		t.Errorf("Expected a single rectangle, got %v", rects)
+	}
+}
+
+func TestMatte(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{0, 0, 0, 0})
+	img.SetNRGBA(1, 0, color.NRGBA{0, 0, 0, 0x80})
+	img.SetNRGBA(2, 0, color.NRGBA{0xff, 0, 0, 0xff})
+	white := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+	pi, err := NewPixelImageWithOptions(img, false, &ImageOptions{Matte: &white})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x, expected := range [][4]int{{0xff, 0xff, 0xff, 0xff}, {0x7f, 0x7f, 0x7f, 0xff}, {0xff, 0, 0, 0xff}} {
+		if r, g, b, a := pi.At2(x, 0); [4]int{r, g, b, a} != expected {
+			t.Errorf("Expected pixel %d to be %v after compositing, got %v", x, expected, [4]int{r, g, b, a})
+		}
+		if pi.Covered(x, 0) {
+			t.Errorf("Expected pixel %d to be opaque after compositing", x)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package png2svg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// pixelArtScale is the number of subcells per pixel, in each direction,
+// that is used when reshaping the pixels along the diagonal connections
+const pixelArtScale = 4
+
+// The 8 directions to the neighbors of a pixel, starting to the east and going clockwise
+var (
+	neighborX = [8]int{1, 1, 0, -1, -1, -1, 0, 1}
+	neighborY = [8]int{0, 1, 1, 1, 0, -1, -1, -1}
+)
+
+// similarityGraph connects each pixel to the neighbors that look alike.
+// Each pixel has a bitmask of neighbors, where bit i is the direction i.
+type similarityGraph struct {
+	w, h  int
+	edges []uint8
+}
+
+// similar checks if two pixels look alike, using the same YUV thresholds as
+// "Depixelizing Pixel Art" by Kopf and Lischinski. Covered pixels are not similar to anything.
+func similar(p, q *Pixel) bool {
+	if p.covered || q.covered {
+		return false
+	}
+	y1, u1, v1 := yuv(p)
+	y2, u2, v2 := yuv(q)
+	return abs(y1-y2) <= 48 && abs(u1-u2) <= 7 && abs(v1-v2) <= 6
+}
+
+// yuv converts the color of a pixel to YUV
+func yuv(p *Pixel) (float64, float64, float64) {
+	r, g, b := float64(p.r), float64(p.g), float64(p.b)
+	y := 0.299*r + 0.587*g + 0.114*b
+	return y, 0.492 * (b - y), 0.877 * (r - y)
+}
+
+// abs returns the absolute value of x
+func abs(x float64) float64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// newSimilarityGraph connects all neighboring pixels that are similar
+func (pi *PixelImage) newSimilarityGraph() *similarityGraph {
+	g := &similarityGraph{w: pi.w, h: pi.h, edges: make([]uint8, pi.w*pi.h)}
+	for y := 0; y < pi.h; y++ {
+		for x := 0; x < pi.w; x++ {
+			for d := 0; d < 8; d++ {
+				nx, ny := x+neighborX[d], y+neighborY[d]
+				if nx < 0 || ny < 0 || nx >= pi.w || ny >= pi.h {
+					continue
+				}
+				if similar(pi.pixels[y*pi.w+x], pi.pixels[ny*pi.w+nx]) {
+					g.edges[y*pi.w+x] |= 1 << d
+				}
+			}
+		}
+	}
+	return g
+}
+
+// connected checks if the pixel at (x, y) is connected to its neighbor in direction d
+func (g *similarityGraph) connected(x, y, d int) bool {
+	return g.edges[y*g.w+x]&(1<<d) != 0
+}
+
+// disconnect removes the connection from (x, y) in direction d, in both directions
+func (g *similarityGraph) disconnect(x, y, d int) {
+	g.edges[y*g.w+x] &^= 1 << d
+	nx, ny := x+neighborX[d], y+neighborY[d]
+	g.edges[ny*g.w+nx] &^= 1 << ((d + 4) % 8)
+}
+
+// valence returns the number of connections of the pixel at (x, y)
+func (g *similarityGraph) valence(x, y int) int {
+	n := 0
+	for e := g.edges[y*g.w+x]; e != 0; e &= e - 1 {
+		n++
+	}
+	return n
+}
+
+// curveLength returns the length of the curve that the connection from (x, y) in direction d
+// is a part of, by following the pixels that have exactly two connections, in both directions
+func (g *similarityGraph) curveLength(x, y, d int) int {
+	length := 1
+	startx, starty := x, y
+	for _, end := range [2][3]int{{x, y, d}, {x + neighborX[d], y + neighborY[d], (d + 4) % 8}} {
+		// Walk away from the connection, starting at one end of it
+		cx, cy, from := end[0], end[1], end[2]
+		for g.valence(cx, cy) == 2 {
+			next := -1
+			for e := 0; e < 8; e++ {
+				if e != from && g.connected(cx, cy, e) {
+					next = e
+					break
+				}
+			}
+			cx, cy, from = cx+neighborX[next], cy+neighborY[next], (next+4)%8
+			length++
+			if cx == startx && cy == starty {
+				// The curve is a closed loop
+				return length
+			}
+		}
+	}
+	return length
+}
+
+// componentSize returns the number of pixels that are connected to the pixel at (x, y),
+// within the 8x8 window that has its top left corner at (wx, wy)
+func (g *similarityGraph) componentSize(x, y, wx, wy int) int {
+	inWindow := func(x, y int) bool {
+		return x >= wx && y >= wy && x < wx+8 && y < wy+8 && x >= 0 && y >= 0 && x < g.w && y < g.h
+	}
+	seen := map[[2]int]bool{{x, y}: true}
+	queue := [][2]int{{x, y}}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for d := 0; d < 8; d++ {
+			if !g.connected(p[0], p[1], d) {
+				continue
+			}
+			q := [2]int{p[0] + neighborX[d], p[1] + neighborY[d]}
+			if !seen[q] && inWindow(q[0], q[1]) {
+				seen[q] = true
+				queue = append(queue, q)
+			}
+		}
+	}
+	return len(seen)
+}
+
+// Directions of the two diagonals in a 2x2 block, from the top left and the top right pixel
+const (
+	southEast = 1
+	southWest = 3
+)
+
+// resolveDiagonals removes crossing diagonal connections in all 2x2 blocks of pixels.
+// If all four pixels are connected, both diagonals are removed, since they don't carry
+// any information. Otherwise the diagonal that is most likely to be a part of a line or
+// a small feature is kept, by weighing the curves, sparse pixels and islands heuristics.
+func (g *similarityGraph) resolveDiagonals() {
+	var crossings [][2]int
+	for y := 0; y+1 < g.h; y++ {
+		for x := 0; x+1 < g.w; x++ {
+			if !g.connected(x, y, southEast) || !g.connected(x+1, y, southWest) {
+				continue
+			}
+			if g.connected(x, y, 0) && g.connected(x, y, 2) && g.connected(x+1, y+1, 4) && g.connected(x+1, y+1, 6) {
+				g.disconnect(x, y, southEast)
+				g.disconnect(x+1, y, southWest)
+				continue
+			}
+			crossings = append(crossings, [2]int{x, y})
+		}
+	}
+	for _, c := range crossings {
+		x, y := c[0], c[1]
+
+		// Curves: favor the diagonal that is a part of the longest curve
+		weight := g.curveLength(x, y, southEast) - g.curveLength(x+1, y, southWest)
+
+		// Sparse pixels: favor the diagonal that connects the fewest pixels, since it is
+		// more likely to be a feature in the foreground
+		weight += g.componentSize(x+1, y, x-3, y-3) - g.componentSize(x, y, x-3, y-3)
+
+		// Islands: avoid leaving a single pixel disconnected from everything
+		if g.valence(x, y) == 1 || g.valence(x+1, y+1) == 1 {
+			weight += 5
+		}
+		if g.valence(x+1, y) == 1 || g.valence(x, y+1) == 1 {
+			weight -= 5
+		}
+
+		switch {
+		case weight > 0:
+			g.disconnect(x+1, y, southWest)
+		case weight < 0:
+			g.disconnect(x, y, southEast)
+		default:
+			g.disconnect(x, y, southEast)
+			g.disconnect(x+1, y, southWest)
+		}
+	}
+}
+
+// alsoConnectedOrthogonally checks if the two pixels of the diagonal connection in the
+// 2x2 block with its top left pixel at (x, y) are also connected through one of the other pixels
+func (g *similarityGraph) alsoConnectedOrthogonally(x, y int) bool {
+	const east, south, west = 0, 2, 4
+	switch {
+	case g.connected(x, y, southEast):
+		return (g.connected(x, y, east) && g.connected(x+1, y, south)) ||
+			(g.connected(x, y, south) && g.connected(x, y+1, east))
+	case g.connected(x+1, y, southWest):
+		return (g.connected(x+1, y, west) && g.connected(x, y, south)) ||
+			(g.connected(x+1, y, south) && g.connected(x+1, y+1, west))
+	}
+	return false
+}
+
+// reshape returns the labels upscaled to subcells, where the pixels that are connected
+// diagonally are joined by cutting the corners of the two other pixels in the 2x2 block
+func (g *similarityGraph) reshape(labels []int) []int {
+	const s = pixelArtScale
+	w, h := g.w*s, g.h*s
+	cells := make([]int, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			cells[y*w+x] = labels[(y/s)*g.w+x/s]
+		}
+	}
+	// cut gives the subcells in the corner of the pixel at (px, py) that is closest to
+	// the corner point (cx, cy), to the given label. The cut goes at 45 degrees.
+	cut := func(px, py, cx, cy, label int) {
+		for j := 0; j < s; j++ {
+			for i := 0; i < s; i++ {
+				sx, sy := px*s+i, py*s+j
+				dx, dy := cx-sx, cy-sy
+				if dx <= 0 {
+					dx = -dx + 1
+				}
+				if dy <= 0 {
+					dy = -dy + 1
+				}
+				if dx+dy <= s/2+1 {
+					cells[sy*w+sx] = label
+				}
+			}
+		}
+	}
+	for y := 0; y+1 < g.h; y++ {
+		for x := 0; x+1 < g.w; x++ {
+			cx, cy := (x+1)*s, (y+1)*s
+			switch {
+			case g.alsoConnectedOrthogonally(x, y):
+				// The diagonal does not add anything, and cutting the corners would round off shapes
+			case g.connected(x, y, southEast):
+				cut(x+1, y, cx, cy, labels[y*g.w+x])
+				cut(x, y+1, cx, cy, labels[(y+1)*g.w+x+1])
+			case g.connected(x+1, y, southWest):
+				cut(x, y, cx, cy, labels[y*g.w+x+1])
+				cut(x+1, y+1, cx, cy, labels[(y+1)*g.w+x])
+			}
+		}
+	}
+	return cells
+}
+
+// TracePixelArt draws all pixels that are not covered yet as smooth shapes, in the style of
+// "Depixelizing Pixel Art" by Kopf and Lischinski. Pixels that look alike are connected in a
+// similarity graph, crossing diagonal connections are resolved, the pixels are reshaped so that
+// the diagonally connected pixels are joined, and the outlines are traced and smoothed with
+// the given options, as for Trace. This is useful for upscaling sprites and retro game art.
+// All pixels are marked as covered.
+func (pi *PixelImage) TracePixelArt(opts *TraceOptions) error {
+	defer pi.timePhase("pixelart")()
+	if opts == nil {
+		opts = NewTraceOptions()
+	}
+	if opts.Tolerance < 0 || opts.Smoothing < 0 {
+		return errors.New("the tolerance and the smoothing can not be negative")
+	}
+	if pi.verbose {
+		fmt.Print("Tracing pixel art...")
+	}
+	labels, fills := pi.labelPixels()
+	if len(fills) > maxTraceColors {
+		return fmt.Errorf("can not trace %d colors, the maximum is %d, reduce the number of colors first", len(fills), maxTraceColors)
+	}
+	g := pi.newSimilarityGraph()
+	g.resolveDiagonals()
+	cells := g.reshape(labels)
+
+	// The tolerance is given in pixels, but the outlines are traced in subcells
+	subcellOptions := *opts
+	subcellOptions.Tolerance *= pixelArtScale
+	pi.elements.Write(traceLayers(cells, pi.w*pixelArtScale, pi.h*pixelArtScale, fills, &subcellOptions, 1.0/pixelArtScale, pi.editable))
+	for _, p := range pi.pixels {
+		p.covered = true
+	}
+	if pi.verbose {
+		fmt.Printf("ok (%d colors)\n", len(fills))
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package png2svg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"regexp"
+	"testing"
+)
+
+// diagonalLine returns a white image with a black line from the top left to the bottom right
+func diagonalLine(size int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+			if x == y {
+				c = color.NRGBA{0, 0, 0, 0xff}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestResolveDiagonals(t *testing.T) {
+	// Where the black diagonal line crosses the white background diagonally,
+	// the line is a longer curve and is sparser, so it should be kept
+	pi := NewPixelImage(diagonalLine(4), false)
+	g := pi.newSimilarityGraph()
+	g.resolveDiagonals()
+	if !g.connected(1, 1, southEast) || g.connected(2, 1, southWest) {
+		t.Error("Expected the diagonal line to be kept and the crossing background diagonal to be removed")
+	}
+
+	// In a block where all pixels are similar, both diagonals are removed
+	pi = NewPixelImage(image.NewNRGBA(image.Rect(0, 0, 2, 2)), false)
+	for _, p := range pi.pixels {
+		p.a, p.covered = 0xff, false
+	}
+	g = pi.newSimilarityGraph()
+	g.resolveDiagonals()
+	if g.connected(0, 0, southEast) || g.connected(1, 0, southWest) {
+		t.Error("Expected both diagonals to be removed in a fully connected block")
+	}
+}
+
+func TestTracePixelArt(t *testing.T) {
+	blackPath := regexp.MustCompile(`<path fill="#000" d="([^"]*)"`)
+
+	// Without reshaping, each pixel of the diagonal line is traced separately
+	pi := NewPixelImage(diagonalLine(6), false)
+	if err := pi.Trace(nil); err != nil {
+		t.Fatal(err)
+	}
+	m := blackPath.FindSubmatch(pi.Bytes())
+	if m == nil || bytes.Count(m[1], []byte("m")) != 6 {
+		t.Fatalf("Expected 6 subpaths when tracing a diagonal line, got %q", m)
+	}
+
+	// With reshaping, the diagonal line is one shape
+	pi = NewPixelImage(diagonalLine(6), false)
+	if err := pi.TracePixelArt(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !pi.Done(0, 0) {
+		t.Error("Expected all pixels to be covered after tracing")
+	}
+	m = blackPath.FindSubmatch(pi.Bytes())
+	if m == nil || bytes.Count(m[1], []byte("m")) != 1 {
+		t.Errorf("Expected 1 subpath when tracing a diagonal line as pixel art, got %q", m)
+	}
+}
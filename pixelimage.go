@@ -2,17 +2,23 @@ package png2svg
 
 import (
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"image/png"
+	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/xyproto/tinysvg"
 )
 
+// xmlDeclaration is the XML declaration that tinysvg places at the start of the SVG document
+const xmlDeclaration = `<?xml version="1.0" encoding="UTF-8"?>`
+
 // Pixel represents a pixel at position (x,y)
 // with color (r,g,b,a)
 // and a bool for if this pixel has been covered by an SVG shape yet
@@ -35,13 +41,39 @@ type Pixels []*Pixel
 // colorOptimize, for if only 4096 colors should be used
 // (short hex color strings, like #fff).
 type PixelImage struct {
-	document      *tinysvg.Document
-	svgTag        *tinysvg.Tag
-	pixels        Pixels
-	w             int
-	h             int
-	verbose       bool
-	colorOptimize bool
+	document         *tinysvg.Document
+	svgTag           *tinysvg.Tag
+	pixels           Pixels
+	w                int
+	h                int
+	verbose          bool
+	colorOptimize    bool
+	gzip             bool
+	precompress      bool
+	compressionLevel int
+	displayWidth     float64
+	displayHeight    float64
+	stretch          bool
+	crispEdges       bool
+	compact          bool
+	encoding         Encoding
+	rects            []Rect
+	defs             bytes.Buffer // elements that are placed inside <defs>
+	prelude          bytes.Buffer // elements that are drawn before the rectangles
+	elements         bytes.Buffer // elements that are drawn after the rectangles, in order
+	tileCount        int
+	gradientCount    int
+	xlink            bool
+	growth           GrowthStrategy
+	groups           int     // the number of <g> elements from the last call to Bytes
+	passes           []Pass  // the passes of the last call to Bytes
+	phases           []Phase // the time that was spent in each phase of the conversion
+	format           Format
+	formatName       string
+	metadata         *Metadata
+	editable         bool
+	editableRegions  bool
+	profile          Profile
 }
 
 // SetColorOptimize can be used to set the colorOptimize flag,
@@ -50,6 +82,31 @@ func (pi *PixelImage) SetColorOptimize(enabled bool) {
 	pi.colorOptimize = enabled
 }
 
+// SetGzip can be used for always writing gzip compressed SVGZ files,
+// also when the output filename does not end with ".svgz".
+func (pi *PixelImage) SetGzip(enabled bool) {
+	pi.gzip = enabled
+}
+
+// SetPrecompress can be used for also writing a gzip compressed copy of the
+// SVG file, with ".gz" appended to the filename. This is useful for web servers
+// that can serve precompressed files, like nginx with "gzip_static on".
+// Brotli compressed copies are not written, since the Go standard library has no
+// Brotli encoder. Use the brotli command on the SVG file if a ".br" copy is needed.
+func (pi *PixelImage) SetPrecompress(enabled bool) {
+	pi.precompress = enabled
+}
+
+// SetCompressionLevel sets the gzip compression level, from 1 (gzip.BestSpeed)
+// to 9 (gzip.BestCompression), which is the default.
+func (pi *PixelImage) SetCompressionLevel(level int) error {
+	if level < gzip.BestSpeed || level > gzip.BestCompression {
+		return fmt.Errorf("invalid compression level %d, must be from %d to %d", level, gzip.BestSpeed, gzip.BestCompression)
+	}
+	pi.compressionLevel = level
+	return nil
+}
+
 // ReadPNG tries to read the given PNG image filename and returns and image.Image
 // and an error. If verbose is true, some basic information is printed to stdout.
 func ReadPNG(filename string, verbose bool) (image.Image, error) {
@@ -80,6 +137,21 @@ func Erase(n int) {
 // NewPixelImage initializes a new PixelImage struct,
 // given an image.Image.
 func NewPixelImage(img image.Image, verbose bool) *PixelImage {
+	pi, _ := NewPixelImageWithOptions(img, verbose, nil) // the default options are always valid
+	return pi
+}
+
+// NewPixelImageWithOptions initializes a new PixelImage struct, given an image.Image
+// and options for how the pixels should be interpreted. If opts is nil, the default options are used.
+func NewPixelImageWithOptions(img image.Image, verbose bool, opts *ImageOptions) (*PixelImage, error) {
+	if opts == nil {
+		opts = NewImageOptions()
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+
 	width := img.Bounds().Max.X - img.Bounds().Min.X
 	height := img.Bounds().Max.Y - img.Bounds().Min.Y
 
@@ -106,7 +178,7 @@ func NewPixelImage(img image.Image, verbose bool) *PixelImage {
 		}
 
 		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
-			c = color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			c = opts.pixelColor(img, x, y)
 			// Mark transparent pixels as already being "covered" (alpha == 0)
 			pixels[i] = &Pixel{x, y, int(c.R), int(c.G), int(c.B), int(c.A), c.A == 0}
 			i++
@@ -121,15 +193,18 @@ func NewPixelImage(img image.Image, verbose bool) *PixelImage {
 		fmt.Println("100%")
 	}
 
-	return &PixelImage{
-		document:      document,
-		svgTag:        svgTag,
-		pixels:        pixels,
-		w:             width,
-		h:             height,
-		verbose:       verbose,
-		colorOptimize: false,
+	pi := &PixelImage{
+		document:         document,
+		svgTag:           svgTag,
+		pixels:           pixels,
+		w:                width,
+		h:                height,
+		verbose:          verbose,
+		colorOptimize:    false,
+		compressionLevel: gzip.BestCompression,
 	}
+	pi.RecordPhase("pixels", time.Since(start))
+	return pi, nil
 }
 
 // Done checks if all pixels are covered, in terms of being represented by an SVG element
@@ -181,7 +256,7 @@ func (pi *PixelImage) CoverAllPixels() {
 	coverCount := 0
 	for _, p := range pi.pixels {
 		if !(*p).covered {
-			pi.svgTag.Pixel((*p).x, (*p).y, int((*p).r), int((*p).g), int((*p).b))
+			pi.addRect((*p).x, (*p).y, 1, 1, hexColorString((*p).r, (*p).g, (*p).b))
 			(*p).covered = true
 			coverCount++
 		}
@@ -200,7 +275,7 @@ func (pi *PixelImage) CoverAllPixelsCallback(callbackFunc func(int, int), Nth in
 	callbackFunc(0, l)
 	for i, p := range pi.pixels {
 		if !(*p).covered {
-			pi.svgTag.Pixel((*p).x, (*p).y, int((*p).r), int((*p).g), int((*p).b))
+			pi.addRect((*p).x, (*p).y, 1, 1, hexColorString((*p).r, (*p).g, (*p).b))
 			(*p).covered = true
 			coverCount++
 		}
@@ -336,15 +411,71 @@ func groupLinesByFillColor(lines [][]byte, colorOptimize bool) [][]byte {
 	return lines
 }
 
+// Replacement of colors that are not shortened, colors that has been shortened
+// and color names to even shorter strings.
+var colorReplacements = map[string][]byte{
+	"#f0ffff": []byte("azure"),
+	"#f5f5dc": []byte("beige"),
+	"#ffe4c4": []byte("bisque"),
+	"#a52a2a": []byte("brown"),
+	"#ff7f50": []byte("coral"),
+	"#ffd700": []byte("gold"),
+	"#808080": []byte("gray"), // "grey" is also possible
+	"#008000": []byte("green"),
+	"#4b0082": []byte("indigo"),
+	"#fffff0": []byte("ivory"),
+	"#f0e68c": []byte("khaki"),
+	"#faf0e6": []byte("linen"),
+	"#800000": []byte("maroon"),
+	"#000080": []byte("navy"),
+	"#808000": []byte("olive"),
+	"#ffa500": []byte("orange"),
+	"#da70d6": []byte("orchid"),
+	"#cd853f": []byte("peru"),
+	"#ffc0cb": []byte("pink"),
+	"#dda0dd": []byte("plum"),
+	"#800080": []byte("purple"),
+	"#f00":    []byte("red"),
+	"#fa8072": []byte("salmon"),
+	"#a0522d": []byte("sienna"),
+	"#c0c0c0": []byte("silver"),
+	"#fffafa": []byte("snow"),
+	"#d2b48c": []byte("tan"),
+	"#008080": []byte("teal"),
+	"#ff6347": []byte("tomato"),
+	"#ee82ee": []byte("violet"),
+	"#f5deb3": []byte("wheat"),
+}
+
+// preludeElements returns the <defs> element, if there are any definitions,
+// followed by the elements that should be drawn before the rectangles
+func (pi *PixelImage) preludeElements() []byte {
+	var buf bytes.Buffer
+	if pi.defs.Len() > 0 {
+		buf.WriteString("<defs>")
+		buf.Write(pi.defs.Bytes())
+		buf.WriteString("</defs>")
+	}
+	buf.Write(pi.prelude.Bytes())
+	return buf.Bytes()
+}
+
 // Bytes returns the rendered SVG document as bytes
 func (pi *PixelImage) Bytes() []byte {
 	if pi.verbose {
 		fmt.Print("Rendering SVG...")
 	}
 
+	// Keep track of the size of the SVG document after each pass
+	defer pi.timePhase("bytes")()
+	pi.passes = pi.passes[:0]
+	start := time.Now()
+
 	// Render the SVG document
 	// TODO: pi.document.WriteTo also exists, and might be faster
+	pi.setRootAttributes()
 	svgDocument := pi.document.Bytes()
+	pi.pass("render", nil, svgDocument, &start)
 
 	if pi.verbose {
 		fmt.Println("ok")
@@ -363,7 +494,15 @@ func (pi *PixelImage) Bytes() []byte {
 		}
 	}
 	// Use the line contents as the new svgDocument
+	before := svgDocument
 	svgDocument = bytes.Join(lines, []byte{})
+	pi.groups = bytes.Count(svgDocument, []byte("<g ")) - bytes.Count(before, []byte("<g "))
+	pi.pass("group", before, svgDocument, &start)
+
+	// Add the definitions and the elements that should be drawn first, which should not be grouped
+	before = svgDocument
+	svgDocument = insertAfterOpeningTag(svgDocument, pi.preludeElements())
+	pi.pass("prelude", before, svgDocument, &start)
 
 	if pi.verbose {
 		fmt.Println("ok")
@@ -384,55 +523,58 @@ func (pi *PixelImage) Bytes() []byte {
 	// Remove empty height attributes
 	// Remove single spaces between tags
 
+	before = svgDocument
 	svgDocument = bytes.Replace(svgDocument, []byte("\n"), []byte{}, -1)
 	svgDocument = bytes.Replace(svgDocument, []byte(" />"), []byte("/>"), -1)
 	svgDocument = bytes.Replace(svgDocument, []byte("  "), []byte(" "), -1)
 	svgDocument = bytes.Replace(svgDocument, []byte(" x=\"0\""), []byte{}, -1)
 	svgDocument = bytes.Replace(svgDocument, []byte(" y=\"0\""), []byte{}, -1)
-	svgDocument = bytes.Replace(svgDocument, []byte(" width=\"0\""), []byte{}, -1)
-	svgDocument = bytes.Replace(svgDocument, []byte(" height=\"0\""), []byte{}, -1)
+	if pi.profile == TinyProfile {
+		// A missing width or height is only the same as 0 in SVG Tiny 1.2
+		svgDocument = bytes.Replace(svgDocument, []byte(" width=\"0\""), []byte{}, -1)
+		svgDocument = bytes.Replace(svgDocument, []byte(" height=\"0\""), []byte{}, -1)
+	}
 	svgDocument = bytes.Replace(svgDocument, []byte("> <"), []byte("><"), -1)
-
-	// Replacement of colors that are not shortened, colors that has been shortened
-	// and color names to even shorter strings.
-	colorReplacements := map[string][]byte{
-		"#f0ffff": []byte("azure"),
-		"#f5f5dc": []byte("beige"),
-		"#ffe4c4": []byte("bisque"),
-		"#a52a2a": []byte("brown"),
-		"#ff7f50": []byte("coral"),
-		"#ffd700": []byte("gold"),
-		"#808080": []byte("gray"), // "grey" is also possible
-		"#008000": []byte("green"),
-		"#4b0082": []byte("indigo"),
-		"#fffff0": []byte("ivory"),
-		"#f0e68c": []byte("khaki"),
-		"#faf0e6": []byte("linen"),
-		"#800000": []byte("maroon"),
-		"#000080": []byte("navy"),
-		"#808000": []byte("olive"),
-		"#ffa500": []byte("orange"),
-		"#da70d6": []byte("orchid"),
-		"#cd853f": []byte("peru"),
-		"#ffc0cb": []byte("pink"),
-		"#dda0dd": []byte("plum"),
-		"#800080": []byte("purple"),
-		"#f00":    []byte("red"),
-		"#fa8072": []byte("salmon"),
-		"#a0522d": []byte("sienna"),
-		"#c0c0c0": []byte("silver"),
-		"#fffafa": []byte("snow"),
-		"#d2b48c": []byte("tan"),
-		"#008080": []byte("teal"),
-		"#ff6347": []byte("tomato"),
-		"#ee82ee": []byte("violet"),
-		"#f5deb3": []byte("wheat"),
+	pi.pass("optimize", before, svgDocument, &start)
+
+	// Add the <path> elements, if PathEncoding is used, and the elements that should be drawn last
+	before = svgDocument
+	if pi.editable {
+		editable := pi.editableElements()
+		pi.groups = bytes.Count(editable, []byte("<g "))
+		svgDocument = insertBeforeClosingTag(svgDocument, editable)
+	} else if pi.encoding == PathEncoding {
+		svgDocument = insertBeforeClosingTag(svgDocument, pi.pathElements())
+	}
+	svgDocument = insertBeforeClosingTag(svgDocument, pi.elements.Bytes())
+	pi.pass("elements", before, svgDocument, &start)
+
+	// Remove the attributes that are not a part of the selected profile
+	before = svgDocument
+	svgDocument = pi.applyProfile(svgDocument)
+	pi.pass("profile", before, svgDocument, &start)
+
+	// The XML declaration is optional for SVG documents
+	if pi.compact {
+		before = svgDocument
+		svgDocument = bytes.TrimPrefix(svgDocument, []byte(xmlDeclaration))
+		pi.pass("compact", before, svgDocument, &start)
 	}
 
 	// Replace colors with the shorter version
-	for k, v := range colorReplacements {
+	before = svgDocument
+	for k, v := range pi.profile.colorReplacements() {
 		svgDocument = bytes.Replace(svgDocument, []byte(k), v, -1)
 	}
+	pi.pass("colors", before, svgDocument, &start)
+
+	// The title, the description and the metadata must be placed before everything else.
+	// They are added last, so that the text is not changed by the passes above.
+	if pi.metadata != nil {
+		before = svgDocument
+		svgDocument = insertAfterOpeningTag(svgDocument, pi.metadata.elements())
+		pi.pass("metadata", before, svgDocument, &start)
+	}
 
 	if pi.verbose {
 		fmt.Println("ok")
@@ -441,20 +583,28 @@ func (pi *PixelImage) Bytes() []byte {
 	return svgDocument
 }
 
-// WriteSVG will save the current SVG document to a file
-func (pi *PixelImage) WriteSVG(filename string) error {
+// writeCompressed writes gzip compressed data to the given io.Writer
+func (pi *PixelImage) writeCompressed(w io.Writer, data []byte) error {
+	gz, err := gzip.NewWriterLevel(w, pi.compressionLevel)
+	if err != nil {
+		return err
+	}
+	if _, err = gz.Write(data); err != nil {
+		gz.Close()
+		return err
+	}
+	return gz.Close()
+}
+
+// writeFile writes the given data to a file, or to stdout if the filename is "-".
+// The data is gzip compressed if compress is true.
+func (pi *PixelImage) writeFile(filename string, data []byte, compress bool) error {
 	var (
 		err error
 		f   *os.File
 	)
-
-	if !pi.Done(0, 0) {
-		return errors.New("the SVG representation does not cover all pixels")
-	}
 	if filename == "-" {
 		f = os.Stdout
-		// Turn off verbose messages, so that they don't end up in the SVG output
-		pi.verbose = false
 	} else {
 		f, err = os.Create(filename)
 		if err != nil {
@@ -462,10 +612,63 @@ func (pi *PixelImage) WriteSVG(filename string) error {
 		}
 		defer f.Close()
 	}
+	if compress {
+		return pi.writeCompressed(f, data)
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// compressed checks if WriteSVG gzip compresses the file with the given filename
+func (pi *PixelImage) compressed(filename string) bool {
+	return pi.gzip || strings.HasSuffix(strings.ToLower(filename), ".svgz")
+}
+
+// OutputSize returns the size in bytes of the file that WriteSVG would write, in the format that
+// has been set with SetFormat, and gzip compressed if the file would be compressed. For plain SVG
+// documents, this is the estimate from EstimateSize. Otherwise the SVG document is rendered.
+func (pi *PixelImage) OutputSize(filename string) (int, error) {
+	compress := pi.compressed(filename)
+	if pi.format == SVGFormat && !compress {
+		return pi.EstimateSize().Size, nil
+	}
+	svgDocument, err := Embed(pi.Bytes(), pi.format, pi.formatName)
+	if err != nil {
+		return 0, err
+	}
+	if !compress {
+		return len(svgDocument), nil
+	}
+	var buf bytes.Buffer
+	if err := pi.writeCompressed(&buf, svgDocument); err != nil {
+		return 0, err
+	}
+	return buf.Len(), nil
+}
+
+// WriteSVG will save the current SVG document to a file, in the format that has been set with SetFormat.
+// The file is gzip compressed if the filename ends with ".svgz" or if SetGzip has been used.
+// If SetPrecompress has been used, a gzip compressed copy is also written to filename + ".gz".
+func (pi *PixelImage) WriteSVG(filename string) error {
+	if !pi.Done(0, 0) {
+		return errors.New("the SVG representation does not cover all pixels")
+	}
+	if filename == "-" {
+		// Turn off verbose messages, so that they don't end up in the SVG output
+		pi.verbose = false
+	}
 
 	// Write the generated SVG image to file or to stdout
-	if _, err = f.Write(pi.Bytes()); err != nil {
+	compress := pi.compressed(filename)
+	svgDocument, err := Embed(pi.Bytes(), pi.format, pi.formatName)
+	if err != nil {
+		return err
+	}
+	if err := pi.writeFile(filename, svgDocument, compress); err != nil {
 		return err
 	}
+	if pi.precompress && !compress && filename != "-" {
+		return pi.writeFile(filename+".gz", svgDocument, true)
+	}
 	return nil
 }
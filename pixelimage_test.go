@@ -1,7 +1,12 @@
 package png2svg
 
 import (
+	"bytes"
+	"compress/gzip"
 	"image/color"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -54,3 +59,35 @@ func TestRainforestPixelColorSpecific(t *testing.T) {
 			targetX, targetY, pixel.r, pixel.g, pixel.b, expectedRed, expectedGreen, expectedBlue)
 	}
 }
+
+func TestWriteSVGZ(t *testing.T) {
+	img, err := ReadPNG("testdata/jumpline16.png", false)
+	if err != nil {
+		t.Fatalf("Failed to read PNG file: %v", err)
+	}
+	pixelImage := NewPixelImage(img, false)
+	pixelImage.CoverAllPixels()
+
+	filename := filepath.Join(t.TempDir(), "jumpline16.svgz")
+	if err := pixelImage.WriteSVG(filename); err != nil {
+		t.Fatalf("Failed to write SVGZ file: %v", err)
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("The written file is not gzip compressed: %v", err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The order of attributes and groups may differ between renderings, but not the length
+	if !bytes.HasPrefix(data, []byte("<?xml")) || len(data) != len(pixelImage.Bytes()) {
+		t.Error("The decompressed SVGZ file differs from the SVG document")
+	}
+}
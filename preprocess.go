@@ -0,0 +1,160 @@
+package png2svg
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// newImageLike returns a new image with the given size, that can hold the colors of img
+// without any loss. Images with premultiplied alpha are kept as they are, so that they
+// can be unpremultiplied correctly by NewPixelImageWithOptions.
+func newImageLike(img image.Image, w, h int) draw.Image {
+	if _, ok := img.(*image.RGBA); ok {
+		return image.NewRGBA(image.Rect(0, 0, w, h))
+	}
+	return image.NewNRGBA(image.Rect(0, 0, w, h))
+}
+
+// Crop returns the part of the image that is inside the given rectangle,
+// where (0, 0) is the top left corner of the image
+func Crop(img image.Image, r image.Rectangle) (image.Image, error) {
+	b := img.Bounds()
+	r = r.Add(b.Min)
+	if r.Empty() || !r.In(b) {
+		return nil, fmt.Errorf("the crop rectangle %v is not inside the %dx%d image", r.Sub(b.Min), b.Dx(), b.Dy())
+	}
+	cropped := newImageLike(img, r.Dx(), r.Dy())
+	draw.Draw(cropped, cropped.Bounds(), img, r.Min, draw.Src)
+	return cropped, nil
+}
+
+// Rotate returns the image rotated clockwise by the given number of degrees,
+// which must be a multiple of 90
+func Rotate(img image.Image, degrees int) (image.Image, error) {
+	if degrees%90 != 0 {
+		return nil, fmt.Errorf("invalid rotation of %d degrees, must be a multiple of 90", degrees)
+	}
+	turns := ((degrees/90)%4 + 4) % 4
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if turns%2 == 1 {
+		w, h = h, w
+	}
+	rotated := newImageLike(img, w, h)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			var rx, ry int
+			switch turns {
+			case 0:
+				rx, ry = x, y
+			case 1:
+				rx, ry = b.Dy()-1-y, x
+			case 2:
+				rx, ry = b.Dx()-1-x, b.Dy()-1-y
+			case 3:
+				rx, ry = y, b.Dx()-1-x
+			}
+			rotated.Set(rx, ry, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return rotated, nil
+}
+
+// Flip returns the image mirrored horizontally (left to right), vertically (top to bottom) or both
+func Flip(img image.Image, horizontal, vertical bool) image.Image {
+	b := img.Bounds()
+	flipped := newImageLike(img, b.Dx(), b.Dy())
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			fx, fy := x, y
+			if horizontal {
+				fx = b.Dx() - 1 - x
+			}
+			if vertical {
+				fy = b.Dy() - 1 - y
+			}
+			flipped.Set(fx, fy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return flipped
+}
+
+// ScaleNearest returns the image scaled to the given size, with nearest-neighbor sampling
+func ScaleNearest(img image.Image, w, h int) (image.Image, error) {
+	if w < 1 || h < 1 {
+		return nil, errors.New("the scaled image must be at least 1x1")
+	}
+	b := img.Bounds()
+	scaled := newImageLike(img, w, h)
+	for y := 0; y < h; y++ {
+		sy := b.Min.Y + (2*y+1)*b.Dy()/(2*h)
+		for x := 0; x < w; x++ {
+			sx := b.Min.X + (2*x+1)*b.Dx()/(2*w)
+			scaled.Set(x, y, img.At(sx, sy))
+		}
+	}
+	return scaled, nil
+}
+
+// gcd returns the greatest common divisor of a and b
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// DetectPixelSize finds the integer "pixel size" of pixel art that has been upscaled with
+// nearest-neighbor scaling, which is the largest size n where the image consists of n x n
+// blocks of a single color, aligned with the top left corner. Returns 1 if the image is not upscaled.
+func DetectPixelSize(img image.Image) int {
+	b := img.Bounds()
+	n := gcd(b.Dx(), b.Dy())
+	// Every run of the same color, in each row and each column, must be a multiple of n
+	same := func(x1, y1, x2, y2 int) bool {
+		r1, g1, b1, a1 := img.At(x1, y1).RGBA()
+		r2, g2, b2, a2 := img.At(x2, y2).RGBA()
+		return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+	}
+	for y := b.Min.Y; y < b.Max.Y && n > 1; y++ {
+		run := 1
+		for x := b.Min.X + 1; x < b.Max.X && n > 1; x++ {
+			if same(x-1, y, x, y) {
+				run++
+				continue
+			}
+			n = gcd(n, run)
+			run = 1
+		}
+	}
+	for x := b.Min.X; x < b.Max.X && n > 1; x++ {
+		run := 1
+		for y := b.Min.Y + 1; y < b.Max.Y && n > 1; y++ {
+			if same(x, y-1, x, y) {
+				run++
+				continue
+			}
+			n = gcd(n, run)
+			run = 1
+		}
+	}
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// Downscale returns pixel art that has been upscaled with nearest-neighbor scaling at its native
+// resolution, together with the pixel size that was detected with DetectPixelSize. The SVG image
+// can be shown at the original size again with SetScale.
+func Downscale(img image.Image) (image.Image, int) {
+	n := DetectPixelSize(img)
+	if n == 1 {
+		return img, 1
+	}
+	b := img.Bounds()
+	native, _ := ScaleNearest(img, b.Dx()/n, b.Dy()/n) // the size is always at least 1x1
+	return native, n
+}
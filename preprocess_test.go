@@ -0,0 +1,99 @@
+package png2svg
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// sameImage checks if two images have the same size and colors
+func sameImage(a, b image.Image) bool {
+	if a.Bounds().Dx() != b.Bounds().Dx() || a.Bounds().Dy() != b.Bounds().Dy() {
+		return false
+	}
+	for y := 0; y < a.Bounds().Dy(); y++ {
+		for x := 0; x < a.Bounds().Dx(); x++ {
+			ca := color.NRGBAModel.Convert(a.At(a.Bounds().Min.X+x, a.Bounds().Min.Y+y))
+			cb := color.NRGBAModel.Convert(b.At(b.Bounds().Min.X+x, b.Bounds().Min.Y+y))
+			if ca != cb {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestRotateAndFlip(t *testing.T) {
+	red, green, blue := color.NRGBA{0xff, 0, 0, 0xff}, color.NRGBA{0, 0xff, 0, 0xff}, color.NRGBA{0, 0, 0xff, 0xff}
+	img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	img.Set(0, 0, red)
+	img.Set(1, 0, green)
+	img.Set(2, 0, blue)
+
+	rotated, err := Rotate(img, 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := rotated.Bounds(); b.Dx() != 1 || b.Dy() != 3 || rotated.At(0, 0) != red || rotated.At(0, 2) != blue {
+		t.Error("Expected the first pixel to be at the top after rotating 90 degrees clockwise")
+	}
+	if rotated, _ := Rotate(img, -90); rotated.At(0, 0) != blue {
+		t.Error("Expected the last pixel to be at the top after rotating 90 degrees counter-clockwise")
+	}
+	if rotated, _ := Rotate(img, 360); !sameImage(rotated, img) {
+		t.Error("Expected the image to be the same after rotating 360 degrees")
+	}
+	if _, err := Rotate(img, 45); err == nil {
+		t.Error("Expected an error when rotating 45 degrees")
+	}
+	if rotated, _ := Rotate(img, 180); !sameImage(rotated, Flip(img, true, true)) {
+		t.Error("Expected rotating 180 degrees to be the same as flipping both ways")
+	}
+	if flipped := Flip(img, true, false); flipped.At(0, 0) != blue || flipped.At(2, 0) != red {
+		t.Error("Expected the first and the last pixel to swap places when flipping horizontally")
+	}
+}
+
+func TestCrop(t *testing.T) {
+	glenda, err := ReadPNG("img/glenda.png", false)
+	if err != nil {
+		t.Fatalf("Failed to read PNG file: %v", err)
+	}
+	cropped, err := Crop(glenda, image.Rect(10, 20, 30, 25))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := cropped.Bounds(); b.Dx() != 20 || b.Dy() != 5 || cropped.At(0, 0) != color.NRGBAModel.Convert(glenda.At(10, 20)) {
+		t.Errorf("Unexpected cropped image: %v", cropped.Bounds())
+	}
+	if _, err := Crop(glenda, image.Rect(60, 60, 70, 70)); err == nil {
+		t.Error("Expected an error when cropping outside of the image")
+	}
+}
+
+func TestDetectPixelSize(t *testing.T) {
+	glenda, err := ReadPNG("img/glenda.png", false)
+	if err != nil {
+		t.Fatalf("Failed to read PNG file: %v", err)
+	}
+	if n := DetectPixelSize(glenda); n != 1 {
+		t.Errorf("Expected a pixel size of 1 for an image that is not upscaled, got %d", n)
+	}
+	upscaled, err := ScaleNearest(glenda, 64*4, 64*4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	native, n := Downscale(upscaled)
+	if n != 4 {
+		t.Errorf("Expected a pixel size of 4, got %d", n)
+	}
+	if !sameImage(native, glenda) {
+		t.Error("Expected the downscaled image to be the same as the original image")
+	}
+	// A 3x upscaled image where one pixel is different
+	upscaled, _ = ScaleNearest(glenda, 64*3, 64*3)
+	upscaled.(*image.NRGBA).Set(100, 100, color.NRGBA{1, 2, 3, 0xff})
+	if n := DetectPixelSize(upscaled); n != 1 {
+		t.Errorf("Expected a pixel size of 1 when a single pixel differs, got %d", n)
+	}
+}
@@ -0,0 +1,105 @@
+package png2svg
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// Profile selects which version of SVG the SVG document conforms to
+type Profile int
+
+const (
+	// TinyProfile is SVG Tiny 1.2, which is what tinysvg writes (the default).
+	// Only the 16 basic color names are used, and role="img" is the only accessibility attribute.
+	TinyProfile Profile = iota
+	// SVG11Profile is SVG 1.1, which has all the color names, but no role, aria-* or data-* attributes
+	SVG11Profile
+	// SVG2Profile is SVG 2, which has no version attribute, and uses href instead of xlink:href
+	SVG2Profile
+)
+
+// Profiles maps names to profiles, for command line options
+var Profiles = map[string]Profile{
+	"tiny": TinyProfile,
+	"1.1":  SVG11Profile,
+	"2":    SVG2Profile,
+}
+
+// ProfileByName returns the profile with the given name, like "tiny", "1.1" or "2"
+func ProfileByName(name string) (Profile, error) {
+	if profile, ok := Profiles[name]; ok {
+		return profile, nil
+	}
+	return TinyProfile, fmt.Errorf("unknown profile %q, must be \"tiny\", \"1.1\" or \"2\"", name)
+}
+
+// String returns the name of the profile, like "SVG Tiny 1.2"
+func (p Profile) String() string {
+	switch p {
+	case TinyProfile:
+		return "SVG Tiny 1.2"
+	case SVG11Profile:
+		return "SVG 1.1"
+	case SVG2Profile:
+		return "SVG 2"
+	}
+	return fmt.Sprintf("Profile(%d)", int(p))
+}
+
+// SetProfile selects which version of SVG the SVG document that Bytes returns conforms to
+func (pi *PixelImage) SetProfile(profile Profile) {
+	pi.profile = profile
+}
+
+// basicColors are the color names that are supported by SVG Tiny 1.2
+var basicColors = []string{"black", "silver", "gray", "white", "maroon", "red", "purple", "fuchsia",
+	"green", "lime", "olive", "yellow", "navy", "blue", "teal", "aqua"}
+
+// tinyColorReplacements are the replacements from colorReplacements that use basic color names
+var tinyColorReplacements = func() map[string][]byte {
+	replacements := make(map[string][]byte)
+	for hex, name := range colorReplacements {
+		for _, basic := range basicColors {
+			if string(name) == basic {
+				replacements[hex] = name
+			}
+		}
+	}
+	return replacements
+}()
+
+// colorReplacements returns the colors that can be replaced with shorter color names in this profile
+func (p Profile) colorReplacements() map[string][]byte {
+	if p == TinyProfile {
+		return tinyColorReplacements
+	}
+	return colorReplacements
+}
+
+// ariaAttribute and dataAttribute match aria-* and data-* attributes, which are only a part of SVG 2
+var (
+	ariaAttribute = regexp.MustCompile(` aria-[a-z]+="[^"]*"`)
+	dataAttribute = regexp.MustCompile(` data-[a-z-]+="[^"]*"`)
+)
+
+// applyProfile removes and replaces attributes that are not a part of the selected profile.
+// The attributes of the <svg> tag are only changed if the given document contains the <svg> tag.
+func (pi *PixelImage) applyProfile(svgDocument []byte) []byte {
+	switch pi.profile {
+	case TinyProfile:
+		svgDocument = ariaAttribute.ReplaceAll(svgDocument, nil)
+		svgDocument = dataAttribute.ReplaceAll(svgDocument, nil)
+	case SVG11Profile:
+		svgDocument = removeRootAttribute(svgDocument, "baseProfile")
+		svgDocument = removeRootAttribute(svgDocument, "role")
+		svgDocument = ariaAttribute.ReplaceAll(svgDocument, nil)
+		svgDocument = dataAttribute.ReplaceAll(svgDocument, nil)
+	case SVG2Profile:
+		svgDocument = removeRootAttribute(svgDocument, "version")
+		svgDocument = removeRootAttribute(svgDocument, "baseProfile")
+		svgDocument = removeRootAttribute(svgDocument, "xmlns:xlink")
+		svgDocument = bytes.ReplaceAll(svgDocument, []byte(" xlink:href=\""), []byte(" href=\""))
+	}
+	return svgDocument
+}
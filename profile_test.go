@@ -0,0 +1,247 @@
+package png2svg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// profileAttributes lists the elements that png2svg writes, and the attributes that all profiles allow for them
+var profileAttributes = map[string][]string{
+	"svg":            {"xmlns", "viewBox", "width", "height", "preserveAspectRatio", "shape-rendering"},
+	"g":              {"id", "fill"},
+	"rect":           {"x", "y", "width", "height", "fill"},
+	"path":           {"id", "d", "fill"},
+	"circle":         {"cx", "cy", "r", "fill"},
+	"ellipse":        {"cx", "cy", "rx", "ry", "fill"},
+	"defs":           {},
+	"use":            {"x", "y"},
+	"linearGradient": {"id", "x1", "y1", "x2", "y2"},
+	"stop":           {"offset", "stop-color"},
+	"title":          {"id"},
+	"desc":           {"id"},
+	"metadata":       {},
+}
+
+// profileExtraAttributes lists the attributes that only some of the profiles allow
+var profileExtraAttributes = map[Profile]map[string][]string{
+	TinyProfile: {
+		"svg": {"version", "baseProfile", "role", "xmlns:xlink"},
+		"use": {"xlink:href"},
+	},
+	SVG11Profile: {
+		"svg": {"version", "xmlns:xlink"},
+		"use": {"xlink:href"},
+	},
+	SVG2Profile: {
+		"svg":  {"role", "aria-labelledby"},
+		"g":    {"data-color"},
+		"path": {"data-color"},
+		"use":  {"href"},
+	},
+}
+
+// profileVersions are the values of the version and baseProfile attributes of the <svg> tag, if any
+var profileVersions = map[Profile][2]string{
+	TinyProfile:  {"1.2", "tiny"},
+	SVG11Profile: {"1.1", ""},
+	SVG2Profile:  {"", ""},
+}
+
+// validateProfile checks that the elements and attributes of the given node and its children
+// are allowed by the profile. The contents of <metadata> are in other namespaces, and are not checked.
+func validateProfile(t *testing.T, profile Profile, n *xmlNode) {
+	allowed, ok := profileAttributes[n.name]
+	if !ok {
+		t.Errorf("%s: the <%s> element is not allowed", profile, n.name)
+		return
+	}
+	allowed = slices.Concat(allowed, profileExtraAttributes[profile][n.name])
+	attrs := make(map[string]string)
+	for _, attr := range n.attrs {
+		name := qualifiedName(attr.Name)
+		attrs[name] = attr.Value
+		// Attributes and namespace declarations for Inkscape are allowed everywhere
+		if attr.Name.Space == "inkscape" || name == "xmlns:inkscape" {
+			continue
+		}
+		found := false
+		for _, a := range allowed {
+			found = found || a == name
+		}
+		if !found {
+			t.Errorf("%s: the %s attribute is not allowed for <%s>", profile, name, n.name)
+		}
+	}
+	for _, name := range []string{"fill", "stop-color"} {
+		value, ok := attrs[name]
+		if !ok || strings.HasPrefix(value, "#") || strings.HasPrefix(value, "url(") {
+			continue
+		}
+		if _, err := parseColor(value); err != nil {
+			t.Errorf("%s: invalid color %q", profile, value)
+		}
+		if profile == TinyProfile {
+			found := false
+			for _, basic := range basicColors {
+				found = found || basic == value
+			}
+			if !found {
+				t.Errorf("%s: the color name %q is not allowed", profile, value)
+			}
+		}
+	}
+	switch n.name {
+	case "svg":
+		expected := profileVersions[profile]
+		if attrs["version"] != expected[0] || attrs["baseProfile"] != expected[1] {
+			t.Errorf("%s: expected version %q and baseProfile %q, got %q and %q",
+				profile, expected[0], expected[1], attrs["version"], attrs["baseProfile"])
+		}
+	case "rect":
+		// A missing width or height is only the same as 0 in SVG Tiny 1.2
+		if _, ok := attrs["width"]; !ok && profile != TinyProfile {
+			t.Errorf("%s: <rect> must have a width", profile)
+		}
+		if _, ok := attrs["height"]; !ok && profile != TinyProfile {
+			t.Errorf("%s: <rect> must have a height", profile)
+		}
+	case "metadata":
+		return
+	}
+	for _, child := range n.children {
+		validateProfile(t, profile, child)
+	}
+}
+
+// profileTestImage returns an image with a gradient, a circle and repeated tiles, where the
+// tiles use colors that have names in SVG 1.1 and SVG 2, but not in SVG Tiny 1.2
+func profileTestImage() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 48))
+	for y := 0; y < 48; y++ {
+		for x := 0; x < 64; x++ {
+			c := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+			switch dx, dy := x-20, y-22; {
+			case y < 8:
+				c = color.NRGBA{0, 0, uint8(x * 4), 0xff}
+			case dx*dx+dy*dy <= 100:
+				c = color.NRGBA{0xff, 0, 0, 0xff}
+			case y >= 36 && (x%8 < 4) == (y%8 < 4):
+				c = color.NRGBA{0xf0, 0xff, 0xff, 0xff}
+			case y >= 36 && x%8 == 7:
+				c = color.NRGBA{0x80, 0x80, 0x80, 0xff}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestProfiles(t *testing.T) {
+	glenda, err := ReadPNG("img/glenda.png", false)
+	if err != nil {
+		t.Fatalf("Failed to read PNG file: %v", err)
+	}
+	img := profileTestImage()
+	for _, profile := range []Profile{TinyProfile, SVG11Profile, SVG2Profile} {
+		// Gradients, shapes and tiles
+		pi := NewPixelImage(img, false)
+		pi.SetProfile(profile)
+		if _, err := pi.DetectGradients(2, 4); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pi.DetectEllipses(0.05, 4); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := pi.DeduplicateTiles(8, 8); err != nil {
+			t.Fatal(err)
+		}
+		pi.coverBoxes()
+		features := pi.Bytes()
+		for _, element := range []string{"<linearGradient ", "<circle ", "<use "} {
+			if !bytes.Contains(features, []byte(element)) {
+				t.Errorf("%s: expected the test image to contain %s", profile, element)
+			}
+		}
+
+		// Editable layers, accessibility metadata and the other options
+		pi = NewPixelImage(glenda, false)
+		pi.SetProfile(profile)
+		pi.SetEditable(true, true)
+		pi.SetEncoding(PathEncoding)
+		pi.SetMetadata(&Metadata{Title: "Glenda", Description: "The Plan 9 bunny", Author: "Renée French", Generator: true})
+		pi.SetCrispEdges(true)
+		pi.SetCompact(true)
+		if err := pi.SetScale(2); err != nil {
+			t.Fatal(err)
+		}
+		pi.coverBoxes()
+		editable := pi.Bytes()
+
+		// Traced outlines
+		pi = NewPixelImage(glenda, false)
+		pi.SetProfile(profile)
+		pi.SetEditable(true, false)
+		if err := pi.Trace(NewTraceOptions()); err != nil {
+			t.Fatal(err)
+		}
+		traced := pi.Bytes()
+
+		for _, document := range []struct {
+			name        string
+			svgDocument []byte
+			expected    image.Image // nil if the conversion is lossy
+		}{
+			{"features", features, img},
+			{"editable", editable, glenda},
+			{"traced", traced, nil},
+		} {
+			root, err := parseNodes(document.svgDocument)
+			if err != nil {
+				t.Fatalf("%s: failed to parse the %s SVG document: %v", profile, document.name, err)
+			}
+			validateProfile(t, profile, root)
+			if document.expected == nil {
+				continue
+			}
+
+			// The profile does not change how the image is drawn
+			rendered, err := Rasterize(document.svgDocument)
+			if err != nil {
+				t.Fatalf("%s: failed to rasterize the %s SVG document: %v", profile, document.name, err)
+			}
+			if differences, err := CompareImages(document.expected, rendered, 0); err != nil || differences != 0 {
+				t.Errorf("%s: expected no differences in the %s SVG document, got %d (%v)", profile, document.name, differences, err)
+			}
+		}
+	}
+}
+
+func TestProfileByName(t *testing.T) {
+	for name, expected := range map[string]Profile{"tiny": TinyProfile, "1.1": SVG11Profile, "2": SVG2Profile} {
+		if profile, err := ProfileByName(name); err != nil || profile != expected {
+			t.Errorf("Expected %q to be %s, got %s (%v)", name, expected, profile, err)
+		}
+	}
+	if _, err := ProfileByName("full"); err == nil {
+		t.Error("Expected an error for an unknown profile")
+	}
+}
+
+func TestRemoveRootAttribute(t *testing.T) {
+	svgDocument := []byte(`<svg version="1.2" baseProfile="tiny" viewBox="0 0 1 1"><g version="1"/></svg>`)
+	expected := `<svg version="1.2" viewBox="0 0 1 1"><g version="1"/></svg>`
+	if s := string(removeRootAttribute(svgDocument, "baseProfile")); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+	expected = `<svg baseProfile="tiny" viewBox="0 0 1 1"><g version="1"/></svg>`
+	if s := string(removeRootAttribute(svgDocument, "version")); s != expected {
+		t.Errorf("Expected %s, got %s", expected, s)
+	}
+	if s := string(removeRootAttribute(svgDocument, "role")); s != string(svgDocument) {
+		t.Errorf("Expected the document to be unchanged, got %s", s)
+	}
+}
@@ -0,0 +1,664 @@
+package png2svg
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// svgElement is an element in a parsed SVG document
+type svgElement struct {
+	name     string
+	attrs    map[string]string
+	children []*svgElement
+}
+
+// parseSVG parses an SVG document, which may be gzip compressed, and returns the root element.
+// Namespace prefixes are left out of the element and attribute names.
+func parseSVG(data []byte) (*svgElement, error) {
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		if data, err = io.ReadAll(gz); err != nil {
+			return nil, err
+		}
+	}
+	var (
+		root    *svgElement
+		stack   []*svgElement
+		decoder = xml.NewDecoder(bytes.NewReader(data))
+	)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			e := &svgElement{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr))}
+			for _, attr := range t.Attr {
+				e.attrs[attr.Name.Local] = attr.Value
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, e)
+			} else if root == nil {
+				root = e
+			}
+			stack = append(stack, e)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if root == nil || root.name != "svg" {
+		return nil, errors.New("not an SVG document")
+	}
+	return root, nil
+}
+
+// parseLength parses a number, with an optional "px" unit
+func parseLength(s string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "px"), 64)
+}
+
+// parseFraction parses a number or a percentage, where "50%" is 0.5
+func parseFraction(s string, defaultValue float64) float64 {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return defaultValue
+	}
+	scale := 1.0
+	if strings.HasSuffix(s, "%") {
+		s, scale = strings.TrimSuffix(s, "%"), 0.01
+	}
+	x, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return x * scale
+}
+
+// parseNumbers parses a list of numbers, separated by spaces or commas
+func parseNumbers(s string) ([]float64, error) {
+	var numbers []float64
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ' ' || r == ',' }) {
+		x, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, err
+		}
+		numbers = append(numbers, x)
+	}
+	return numbers, nil
+}
+
+// colorNames contains the color names that png2svg may write, and a few common ones
+var colorNames = func() map[string]string {
+	names := map[string]string{"black": "#000", "white": "#fff", "blue": "#00f", "lime": "#0f0", "yellow": "#ff0"}
+	for hex, name := range colorReplacements {
+		names[string(name)] = hex
+	}
+	return names
+}()
+
+// parseColor parses a color on the form "#rgb" or "#rrggbb", or a color name
+func parseColor(s string) (color.NRGBA, error) {
+	s = strings.TrimSpace(s)
+	if hex, ok := colorNames[s]; ok {
+		s = hex
+	}
+	if !strings.HasPrefix(s, "#") {
+		return color.NRGBA{}, fmt.Errorf("unsupported color %q", s)
+	}
+	return ParseHexColor(s)
+}
+
+// style contains the inherited properties that decide how a shape is filled
+type style struct {
+	fill    string
+	opacity float64
+}
+
+// rasterizer draws the parsed elements of an SVG document onto an image,
+// with one pixel per unit in the viewBox
+type rasterizer struct {
+	img        *image.NRGBA
+	minx, miny float64 // the top left corner of the viewBox
+	ids        map[string]*svgElement
+	visiting   map[string]bool // the ids of the elements that are being drawn by <use> elements
+}
+
+// paint is a function that returns the color at a position in the SVG document
+type paint func(x, y float64) color.NRGBA
+
+// Rasterize renders an SVG document that has been created by png2svg, with one pixel per unit in
+// the viewBox, which is the size of the PNG image. Only the elements and attributes that png2svg
+// uses are supported: <g>, <rect>, <path>, <circle>, <ellipse>, <use> and <linearGradient>, with
+// the fill and fill-opacity attributes. A pixel is filled if its center is inside of a shape,
+// as with shape-rendering="crispEdges". Gzip compressed documents are also supported.
+func Rasterize(data []byte) (*image.NRGBA, error) {
+	root, err := parseSVG(data)
+	if err != nil {
+		return nil, err
+	}
+	var minx, miny, w, h float64
+	if viewBox, ok := root.attrs["viewBox"]; ok {
+		numbers, err := parseNumbers(viewBox)
+		if err != nil || len(numbers) != 4 {
+			return nil, fmt.Errorf("invalid viewBox %q", viewBox)
+		}
+		minx, miny, w, h = numbers[0], numbers[1], numbers[2], numbers[3]
+	} else {
+		w, err = parseLength(root.attrs["width"])
+		if err != nil {
+			return nil, errors.New("the SVG document has no viewBox and no valid width")
+		}
+		h, err = parseLength(root.attrs["height"])
+		if err != nil {
+			return nil, errors.New("the SVG document has no viewBox and no valid height")
+		}
+	}
+	if w <= 0 || h <= 0 {
+		return nil, errors.New("the SVG document is empty")
+	}
+	r := &rasterizer{
+		img:      image.NewNRGBA(image.Rect(0, 0, int(math.Ceil(w)), int(math.Ceil(h)))),
+		minx:     minx,
+		miny:     miny,
+		ids:      make(map[string]*svgElement),
+		visiting: make(map[string]bool),
+	}
+	r.collectIDs(root)
+	if err := r.renderChildren(root, style{"black", 1}, 0, 0); err != nil {
+		return nil, err
+	}
+	return r.img, nil
+}
+
+// collectIDs finds all elements with an id attribute
+func (r *rasterizer) collectIDs(e *svgElement) {
+	if id, ok := e.attrs["id"]; ok {
+		r.ids[id] = e
+	}
+	for _, child := range e.children {
+		r.collectIDs(child)
+	}
+}
+
+// renderChildren renders the children of an element, in order
+func (r *rasterizer) renderChildren(e *svgElement, st style, dx, dy float64) error {
+	for _, child := range e.children {
+		if err := r.render(child, st, dx, dy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attr returns the numeric value of an attribute, or 0 if it is missing
+func (e *svgElement) attr(name string) (float64, error) {
+	s, ok := e.attrs[name]
+	if !ok {
+		return 0, nil
+	}
+	x, err := parseLength(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s attribute %q in <%s>", name, s, e.name)
+	}
+	return x, nil
+}
+
+// numbers returns the numeric values of the given attributes, where missing attributes are 0
+func (e *svgElement) numbers(names ...string) ([]float64, error) {
+	values := make([]float64, len(names))
+	for i, name := range names {
+		x, err := e.attr(name)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = x
+	}
+	return values, nil
+}
+
+// render renders an element, where (dx, dy) is added to all coordinates
+func (r *rasterizer) render(e *svgElement, st style, dx, dy float64) error {
+	if fill, ok := e.attrs["fill"]; ok {
+		st.fill = fill
+	}
+	if opacity, ok := e.attrs["fill-opacity"]; ok {
+		st.opacity = parseFraction(opacity, 1)
+	}
+	switch e.name {
+	case "g", "svg":
+		return r.renderChildren(e, st, dx, dy)
+	case "use":
+		href, ok := e.attrs["href"]
+		id := strings.TrimPrefix(href, "#")
+		target := r.ids[id]
+		if !ok || target == nil {
+			return fmt.Errorf("<use> refers to an unknown element %q", href)
+		}
+		// An element that refers to itself, directly or through its children, would never be done
+		if r.visiting[id] {
+			return fmt.Errorf("<use> refers to %q, which contains the <use> element itself", href)
+		}
+		xy, err := e.numbers("x", "y")
+		if err != nil {
+			return err
+		}
+		r.visiting[id] = true
+		defer delete(r.visiting, id)
+		return r.render(target, st, dx+xy[0], dy+xy[1])
+	case "rect":
+		v, err := e.numbers("x", "y", "width", "height")
+		if err != nil {
+			return err
+		}
+		x, y, w, h := v[0]+dx, v[1]+dy, v[2], v[3]
+		return r.fill(st, x, y, w, h, func(px, py float64) bool {
+			return px >= x && px < x+w && py >= y && py < y+h
+		})
+	case "circle", "ellipse":
+		v, err := e.numbers("cx", "cy", "r", "rx", "ry")
+		if err != nil {
+			return err
+		}
+		cx, cy, rx, ry := v[0]+dx, v[1]+dy, v[3], v[4]
+		if e.name == "circle" {
+			rx, ry = v[2], v[2]
+		}
+		if rx <= 0 || ry <= 0 {
+			return nil
+		}
+		return r.fill(st, cx-rx, cy-ry, 2*rx, 2*ry, func(px, py float64) bool {
+			return ellipse{cx, cy, rx, ry}.inside(int(math.Floor(px)), int(math.Floor(py)))
+		})
+	case "path":
+		subpaths, err := parsePathData(e.attrs["d"])
+		if err != nil {
+			return err
+		}
+		return r.fillPath(st, subpaths, dx, dy)
+	}
+	// Other elements, like <defs>, <title> and <linearGradient>, are not drawn
+	return nil
+}
+
+// paintFor returns the paint for the given style, where (x, y, w, h) is the bounding box of the
+// shape, which is needed for gradients. Returns nil if the shape should not be filled.
+func (r *rasterizer) paintFor(st style, x, y, w, h float64) (paint, error) {
+	if st.fill == "none" {
+		return nil, nil
+	}
+	if strings.HasPrefix(st.fill, "url(") {
+		id := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(st.fill, "url("), "#"), ")")
+		g := r.ids[id]
+		if g == nil || g.name != "linearGradient" {
+			return nil, fmt.Errorf("unknown gradient %q", st.fill)
+		}
+		return linearGradientPaint(g, st.opacity, x, y, w, h)
+	}
+	c, err := parseColor(st.fill)
+	if err != nil {
+		return nil, err
+	}
+	c.A = uint8(math.Round(float64(c.A) * st.opacity))
+	return func(float64, float64) color.NRGBA { return c }, nil
+}
+
+// gradientStop is a color at an offset along a gradient
+type gradientStop struct {
+	offset float64
+	c      color.NRGBA
+}
+
+// linearGradientPaint returns the paint for a <linearGradient> element, with gradientUnits
+// set to objectBoundingBox (the default), where (x, y, w, h) is the bounding box of the shape
+func linearGradientPaint(g *svgElement, opacity, x, y, w, h float64) (paint, error) {
+	x1, y1 := parseFraction(g.attrs["x1"], 0), parseFraction(g.attrs["y1"], 0)
+	x2, y2 := parseFraction(g.attrs["x2"], 1), parseFraction(g.attrs["y2"], 0)
+	var stops []gradientStop
+	for _, child := range g.children {
+		if child.name != "stop" {
+			continue
+		}
+		c, err := parseColor(child.attrs["stop-color"])
+		if err != nil {
+			return nil, err
+		}
+		c.A = uint8(math.Round(float64(c.A) * parseFraction(child.attrs["stop-opacity"], 1) * opacity))
+		offset := math.Max(0, math.Min(1, parseFraction(child.attrs["offset"], 0)))
+		if len(stops) > 0 && offset < stops[len(stops)-1].offset {
+			offset = stops[len(stops)-1].offset
+		}
+		stops = append(stops, gradientStop{offset, c})
+	}
+	if len(stops) == 0 {
+		return nil, nil
+	}
+	length := (x2-x1)*(x2-x1) + (y2-y1)*(y2-y1)
+	return func(px, py float64) color.NRGBA {
+		u, v := (px-x)/w, (py-y)/h
+		t := 0.0
+		if length > 0 {
+			t = ((u-x1)*(x2-x1) + (v-y1)*(y2-y1)) / length
+		}
+		if t <= stops[0].offset {
+			return stops[0].c
+		}
+		for i := 1; i < len(stops); i++ {
+			if t > stops[i].offset {
+				continue
+			}
+			a, b := stops[i-1], stops[i]
+			f := 0.0
+			if b.offset > a.offset {
+				f = (t - a.offset) / (b.offset - a.offset)
+			}
+			mix := func(p, q uint8) uint8 {
+				return uint8(math.Round(float64(p) + (float64(q)-float64(p))*f))
+			}
+			return color.NRGBA{mix(a.c.R, b.c.R), mix(a.c.G, b.c.G), mix(a.c.B, b.c.B), mix(a.c.A, b.c.A)}
+		}
+		return stops[len(stops)-1].c
+	}, nil
+}
+
+// fill fills all pixels within the bounding box (x, y, w, h) where the center is inside the shape
+func (r *rasterizer) fill(st style, x, y, w, h float64, inside func(px, py float64) bool) error {
+	p, err := r.paintFor(st, x, y, w, h)
+	if err != nil || p == nil {
+		return err
+	}
+	b := r.img.Bounds()
+	x0, y0 := max(0, int(math.Floor(x-r.minx))), max(0, int(math.Floor(y-r.miny)))
+	x1, y1 := min(b.Dx(), int(math.Ceil(x+w-r.minx))), min(b.Dy(), int(math.Ceil(y+h-r.miny)))
+	for j := y0; j < y1; j++ {
+		py := r.miny + float64(j) + 0.5
+		for i := x0; i < x1; i++ {
+			px := r.minx + float64(i) + 0.5
+			if inside(px, py) {
+				r.blend(i, j, p(px, py))
+			}
+		}
+	}
+	return nil
+}
+
+// blend draws the color c over the pixel at (i, j)
+func (r *rasterizer) blend(i, j int, c color.NRGBA) {
+	if c.A == 0xff {
+		r.img.SetNRGBA(i, j, c)
+		return
+	}
+	d := r.img.NRGBAAt(i, j)
+	as, ad := float64(c.A)/0xff, float64(d.A)/0xff
+	ao := as + ad*(1-as)
+	if ao == 0 {
+		return
+	}
+	channel := func(s, d uint8) uint8 {
+		return uint8(math.Round((float64(s)*as + float64(d)*ad*(1-as)) / ao))
+	}
+	r.img.SetNRGBA(i, j, color.NRGBA{channel(c.R, d.R), channel(c.G, d.G), channel(c.B, d.B), uint8(math.Round(ao * 0xff))})
+}
+
+// fillPath fills the subpaths with the nonzero fill rule, by finding where the edges cross the
+// horizontal line through the centers of each row of pixels
+func (r *rasterizer) fillPath(st style, subpaths [][]point, dx, dy float64) error {
+	minx, miny, maxx, maxy := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, subpath := range subpaths {
+		for i := range subpath {
+			subpath[i].x += dx
+			subpath[i].y += dy
+			p := subpath[i]
+			minx, miny = math.Min(minx, p.x), math.Min(miny, p.y)
+			maxx, maxy = math.Max(maxx, p.x), math.Max(maxy, p.y)
+		}
+	}
+	if minx > maxx {
+		return nil
+	}
+	p, err := r.paintFor(st, minx, miny, maxx-minx, maxy-miny)
+	if err != nil || p == nil {
+		return err
+	}
+	type crossing struct {
+		x       float64
+		winding int
+	}
+	b := r.img.Bounds()
+	var crossings []crossing
+	for j := max(0, int(math.Floor(miny-r.miny))); j < min(b.Dy(), int(math.Ceil(maxy-r.miny))); j++ {
+		py := r.miny + float64(j) + 0.5
+		crossings = crossings[:0]
+		for _, subpath := range subpaths {
+			for k := range subpath {
+				a, c := subpath[k], subpath[(k+1)%len(subpath)]
+				if a.y == c.y || py < math.Min(a.y, c.y) || py >= math.Max(a.y, c.y) {
+					continue
+				}
+				winding := 1
+				if c.y < a.y {
+					winding = -1
+				}
+				crossings = append(crossings, crossing{a.x + (py-a.y)*(c.x-a.x)/(c.y-a.y), winding})
+			}
+		}
+		sort.Slice(crossings, func(a, b int) bool { return crossings[a].x < crossings[b].x })
+		winding := 0
+		for k := 0; k+1 < len(crossings); k++ {
+			winding += crossings[k].winding
+			if winding == 0 {
+				continue
+			}
+			// Fill the pixels where the center is between the two crossings
+			for i := max(0, int(math.Ceil(crossings[k].x-r.minx-0.5))); i < b.Dx(); i++ {
+				px := r.minx + float64(i) + 0.5
+				if px >= crossings[k+1].x {
+					break
+				}
+				r.blend(i, j, p(px, py))
+			}
+		}
+	}
+	return nil
+}
+
+// pathScanner reads the commands and numbers of the "d" attribute of a path
+type pathScanner struct {
+	s   string
+	pos int
+}
+
+// skip skips spaces and commas
+func (ps *pathScanner) skip() {
+	for ps.pos < len(ps.s) && strings.IndexByte(" \t\r\n,", ps.s[ps.pos]) >= 0 {
+		ps.pos++
+	}
+}
+
+// command returns the next command letter, or 0 if the next token is a number or the end
+func (ps *pathScanner) command() byte {
+	ps.skip()
+	if ps.pos < len(ps.s) {
+		if c := ps.s[ps.pos]; (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') {
+			if c != 'e' && c != 'E' {
+				ps.pos++
+				return c
+			}
+		}
+	}
+	return 0
+}
+
+// done checks if there is nothing more to read
+func (ps *pathScanner) done() bool {
+	ps.skip()
+	return ps.pos >= len(ps.s)
+}
+
+// number reads the next number
+func (ps *pathScanner) number() (float64, error) {
+	ps.skip()
+	start, dot := ps.pos, false
+	for i := ps.pos; i < len(ps.s); i++ {
+		c := ps.s[i]
+		switch {
+		case c >= '0' && c <= '9':
+		case (c == '-' || c == '+') && (i == start || ps.s[i-1] == 'e' || ps.s[i-1] == 'E'):
+		case c == '.' && !dot:
+			dot = true
+		case (c == 'e' || c == 'E') && i > start:
+		default:
+			ps.pos = i
+			return strconv.ParseFloat(ps.s[start:i], 64)
+		}
+		ps.pos = i + 1
+	}
+	return strconv.ParseFloat(ps.s[start:], 64)
+}
+
+// parsePathData parses the "d" attribute of a path, with the commands that png2svg uses
+// (M, L, H, V, C and Z, both absolute and relative), and returns the subpaths as polygons,
+// where the curves have been flattened to line segments
+func parsePathData(d string) ([][]point, error) {
+	const curveSegments = 16
+	var (
+		subpaths [][]point
+		current  []point
+		pos      point // the current position
+		start    point // the start of the current subpath
+		command  byte
+		ps       = &pathScanner{s: d}
+	)
+	finish := func() {
+		if len(current) > 1 {
+			subpaths = append(subpaths, current)
+		}
+		current = nil
+	}
+	for !ps.done() {
+		if c := ps.command(); c != 0 {
+			command = c
+		} else if command == 0 {
+			return nil, fmt.Errorf("invalid path data %q", d)
+		}
+		relative := command >= 'a'
+		origin := point{}
+		if relative {
+			origin = pos
+		}
+		// read reads n numbers
+		read := func(n int) ([]float64, error) {
+			numbers := make([]float64, n)
+			for i := range numbers {
+				x, err := ps.number()
+				if err != nil {
+					return nil, fmt.Errorf("invalid path data %q", d)
+				}
+				numbers[i] = x
+			}
+			return numbers, nil
+		}
+		switch command {
+		case 'Z', 'z':
+			finish()
+			pos = start
+			continue
+		case 'M', 'm':
+			v, err := read(2)
+			if err != nil {
+				return nil, err
+			}
+			finish()
+			pos = point{origin.x + v[0], origin.y + v[1]}
+			start = pos
+			current = []point{pos}
+			// Numbers after a move command are line commands
+			command = 'L' + (command - 'M')
+			continue
+		case 'L', 'l':
+			v, err := read(2)
+			if err != nil {
+				return nil, err
+			}
+			pos = point{origin.x + v[0], origin.y + v[1]}
+		case 'H', 'h':
+			v, err := read(1)
+			if err != nil {
+				return nil, err
+			}
+			pos.x = origin.x + v[0]
+		case 'V', 'v':
+			v, err := read(1)
+			if err != nil {
+				return nil, err
+			}
+			pos.y = origin.y + v[0]
+		case 'C', 'c':
+			v, err := read(6)
+			if err != nil {
+				return nil, err
+			}
+			p0 := pos
+			c1 := point{origin.x + v[0], origin.y + v[1]}
+			c2 := point{origin.x + v[2], origin.y + v[3]}
+			pos = point{origin.x + v[4], origin.y + v[5]}
+			for i := 1; i < curveSegments; i++ {
+				t := float64(i) / curveSegments
+				a, b, c, e := (1-t)*(1-t)*(1-t), 3*(1-t)*(1-t)*t, 3*(1-t)*t*t, t*t*t
+				current = append(current, point{
+					a*p0.x + b*c1.x + c*c2.x + e*pos.x,
+					a*p0.y + b*c1.y + c*c2.y + e*pos.y,
+				})
+			}
+		default:
+			return nil, fmt.Errorf("unsupported path command %q", command)
+		}
+		if current == nil {
+			current = []point{start}
+		}
+		current = append(current, pos)
+	}
+	finish()
+	return subpaths, nil
+}
+
+// CompareImages returns the number of pixels that differ between two images of the same size,
+// where a pixel differs if any of the color channels or the alpha value differ by more than the
+// tolerance. Pixels that are fully transparent in both images are always the same.
+func CompareImages(a, b image.Image, tolerance int) (int, error) {
+	ab, bb := a.Bounds(), b.Bounds()
+	if ab.Dx() != bb.Dx() || ab.Dy() != bb.Dy() {
+		return 0, fmt.Errorf("the images have different sizes, %dx%d and %dx%d", ab.Dx(), ab.Dy(), bb.Dx(), bb.Dy())
+	}
+	differences := 0
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			ca := color.NRGBAModel.Convert(a.At(ab.Min.X+x, ab.Min.Y+y)).(color.NRGBA)
+			cb := color.NRGBAModel.Convert(b.At(bb.Min.X+x, bb.Min.Y+y)).(color.NRGBA)
+			if ca.A == 0 && cb.A == 0 {
+				continue
+			}
+			if absDiff(ca.R, cb.R) > tolerance || absDiff(ca.G, cb.G) > tolerance ||
+				absDiff(ca.B, cb.B) > tolerance || absDiff(ca.A, cb.A) > tolerance {
+				differences++
+			}
+		}
+	}
+	return differences, nil
+}
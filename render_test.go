@@ -0,0 +1,121 @@
+package png2svg
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestRasterize(t *testing.T) {
+	glenda, err := ReadPNG("img/glenda.png", false)
+	if err != nil {
+		t.Fatalf("Failed to read PNG file: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		convert func(pi *PixelImage) error
+	}{
+		{"rect", func(pi *PixelImage) error { return nil }},
+		{"path", func(pi *PixelImage) error {
+			pi.SetEncoding(PathEncoding)
+			return nil
+		}},
+		{"background", func(pi *PixelImage) error {
+			_, err := pi.DrawBackground(color.NRGBA{0xff, 0xff, 0xff, 0xff})
+			return err
+		}},
+		{"trace", func(pi *PixelImage) error {
+			opts := NewTraceOptions()
+			opts.Tolerance = 0
+			return pi.Trace(opts)
+		}},
+	} {
+		pi := NewPixelImage(glenda, false)
+		if err := tc.convert(pi); err != nil {
+			t.Fatal(err)
+		}
+		pi.coverBoxes()
+		img, err := Rasterize(pi.Bytes())
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if n, err := CompareImages(glenda, img, 0); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		} else if n > 0 {
+			t.Errorf("%s: %d pixels differ from the original image", tc.name, n)
+		}
+	}
+}
+
+func TestRasterizeShapes(t *testing.T) {
+	svgDocument := []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 4 4">` +
+		`<defs><linearGradient id="g0"><stop offset="0.125" stop-color="#000"/><stop offset="0.875" stop-color="#fff"/></linearGradient>` +
+		`<rect id="t0" width="1" height="1"/></defs>` +
+		`<rect width="4" height="1" fill="url(#g0)"/>` +
+		`<g fill="red" fill-opacity="0.5"><use xlink:href="#t0" x="1" y="2"/></g>` +
+		`<path d="M0 3h2v1h-2z" fill="#00f"/></svg>`)
+	img, err := Rasterize(svgDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		x, y int
+		c    color.NRGBA
+	}{
+		{0, 0, color.NRGBA{0, 0, 0, 0xff}},
+		{1, 0, color.NRGBA{85, 85, 85, 0xff}},
+		{3, 0, color.NRGBA{0xff, 0xff, 0xff, 0xff}},
+		{1, 2, color.NRGBA{0xff, 0, 0, 0x80}},
+		{0, 2, color.NRGBA{}},
+		{1, 3, color.NRGBA{0, 0, 0xff, 0xff}},
+		{2, 3, color.NRGBA{}},
+	} {
+		if c := img.NRGBAAt(tc.x, tc.y); c != tc.c {
+			t.Errorf("Expected %v at (%d, %d), got %v", tc.c, tc.x, tc.y, c)
+		}
+	}
+}
+
+func TestRasterizeUseCycle(t *testing.T) {
+	for _, svgDocument := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 4 4"><use id="u" xlink:href="#u"/></svg>`,
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 4 4"><g id="a"><rect width="1" height="1"/><use xlink:href="#a" x="1"/></g></svg>`,
+		`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 4 4"><defs><g id="a"><use href="#b"/></g><g id="b"><use href="#a"/></g></defs><use href="#a"/></svg>`,
+	} {
+		if _, err := Rasterize([]byte(svgDocument)); err == nil || !strings.Contains(err.Error(), "contains the <use> element itself") {
+			t.Errorf("Expected an error for a <use> element that refers to itself, got %v: %s", err, svgDocument)
+		}
+	}
+
+	// The same element can be used more than once, also from inside another used element
+	svgDocument := `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 4 1"><defs><rect id="r" width="1" height="1"/>` +
+		`<g id="two"><use href="#r"/><use href="#r" x="1"/></g></defs><use href="#two"/><use href="#two" x="2"/></svg>`
+	img, err := Rasterize([]byte(svgDocument))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 0; x < 4; x++ {
+		if c := img.NRGBAAt(x, 0); c != (color.NRGBA{0, 0, 0, 0xff}) {
+			t.Errorf("Expected a black pixel at (%d, 0), got %v", x, c)
+		}
+	}
+}
+
+func TestCompareImages(t *testing.T) {
+	a := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	b := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	a.SetNRGBA(0, 0, color.NRGBA{10, 20, 30, 0xff})
+	b.SetNRGBA(0, 0, color.NRGBA{12, 20, 30, 0xff})
+	a.SetNRGBA(1, 0, color.NRGBA{1, 2, 3, 0})
+	if n, _ := CompareImages(a, b, 0); n != 1 {
+		t.Errorf("Expected 1 differing pixel, got %d", n)
+	}
+	if n, _ := CompareImages(a, b, 2); n != 0 {
+		t.Errorf("Expected no differing pixels, got %d", n)
+	}
+	if _, err := CompareImages(a, image.NewNRGBA(image.Rect(0, 0, 1, 1)), 0); err == nil {
+		t.Error("Expected an error for images with different sizes")
+	}
+}
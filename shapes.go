@@ -0,0 +1,197 @@
+package png2svg
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ShapeStats contains information about the shapes that were found by DetectEllipses
+type ShapeStats struct {
+	Circles  int // the number of <circle> elements
+	Ellipses int // the number of <ellipse> elements
+	Pixels   int // the number of pixels that are drawn by circles and ellipses instead of rectangles
+}
+
+// String returns a short summary of the detected shapes
+func (ss *ShapeStats) String() string {
+	return fmt.Sprintf("%d circles and %d ellipses, covering %d pixels", ss.Circles, ss.Ellipses, ss.Pixels)
+}
+
+// region is a set of connected pixels with the same color, together with the bounding box
+type region struct {
+	pixels     []int // indices into pi.pixels
+	x, y, w, h int
+}
+
+// sameColor checks if two pixels have exactly the same color and alpha
+func sameColor(p, q *Pixel) bool {
+	return p.r == q.r && p.g == q.g && p.b == q.b && p.a == q.a
+}
+
+// regions finds all regions of connected pixels that have the same color,
+// are fully opaque and are not covered yet. Pixels are connected horizontally and vertically.
+func (pi *PixelImage) regions() []region {
+	var (
+		regions []region
+		seen    = make([]bool, len(pi.pixels))
+	)
+	for start, p := range pi.pixels {
+		if seen[start] || p.covered || p.a != 0xff {
+			continue
+		}
+		seen[start] = true
+		r := region{x: pi.w, y: pi.h}
+		maxx, maxy := 0, 0
+		queue := []int{start}
+		for len(queue) > 0 {
+			i := queue[0]
+			queue = queue[1:]
+			r.pixels = append(r.pixels, i)
+			x, y := i%pi.w, i/pi.w
+			r.x, r.y = min(r.x, x), min(r.y, y)
+			maxx, maxy = max(maxx, x), max(maxy, y)
+			for d := 0; d < 8; d += 2 {
+				nx, ny := x+neighborX[d], y+neighborY[d]
+				if nx < 0 || ny < 0 || nx >= pi.w || ny >= pi.h {
+					continue
+				}
+				j := ny*pi.w + nx
+				if !seen[j] && !pi.pixels[j].covered && sameColor(p, pi.pixels[j]) {
+					seen[j] = true
+					queue = append(queue, j)
+				}
+			}
+		}
+		r.w, r.h = maxx-r.x+1, maxy-r.y+1
+		regions = append(regions, r)
+	}
+	return regions
+}
+
+// ellipse is a filled ellipse, in the coordinates of the SVG document
+type ellipse struct {
+	cx, cy, rx, ry float64
+}
+
+// inside checks if the center of the pixel at (x, y) is inside the ellipse,
+// which is how the ellipse is rasterized with shape-rendering="crispEdges"
+func (e ellipse) inside(x, y int) bool {
+	dx := (float64(x) + 0.5 - e.cx) / e.rx
+	dy := (float64(y) + 0.5 - e.cy) / e.ry
+	return dx*dx+dy*dy <= 1
+}
+
+// fitEllipse finds the ellipse that fits the bounding box of the region best, and returns it
+// together with the number of pixels that differ. If the ellipse covers pixels outside of the
+// region that can not be drawn over, ok is false.
+func (pi *PixelImage) fitEllipse(r region) (best ellipse, mismatches int, ok bool) {
+	inRegion := make(map[int]bool, len(r.pixels))
+	for _, i := range r.pixels {
+		inRegion[i] = true
+	}
+	cx, cy := float64(r.x)+float64(r.w)/2, float64(r.y)+float64(r.h)/2
+	mismatches = -1
+	// Drawing programs rasterize circles and ellipses in slightly different ways,
+	// so try a few radii that all fit within the bounding box
+	for _, shrink := range []float64{0, 0.25, 0.5} {
+		e := ellipse{cx, cy, float64(r.w)/2 - shrink, float64(r.h)/2 - shrink}
+		n, drawable := 0, true
+		for y := r.y; y < r.y+r.h && drawable; y++ {
+			for x := r.x; x < r.x+r.w; x++ {
+				i := y*pi.w + x
+				switch in := e.inside(x, y); {
+				case in && !inRegion[i]:
+					// Pixels outside of the region are drawn over with rectangles afterwards,
+					// so they must not be covered already or be transparent
+					if p := pi.pixels[i]; p.covered || p.a != 0xff {
+						drawable = false
+					}
+					n++
+				case !in && inRegion[i]:
+					n++
+				}
+			}
+		}
+		if drawable && (mismatches < 0 || n < mismatches) {
+			best, mismatches = e, n
+		}
+	}
+	return best, mismatches, mismatches >= 0
+}
+
+// regionImage returns a new PixelImage with the pixels in the bounding box of the region,
+// where the pixels that are not in the region are covered, and only the rectangles are recorded
+func (pi *PixelImage) regionImage(r region) *PixelImage {
+	sub := pi.tileImage(tile{r.x, r.y}, r.w, r.h)
+	for _, p := range sub.pixels {
+		p.covered = true
+	}
+	for _, i := range r.pixels {
+		sub.pixels[(i/pi.w-r.y)*r.w+i%pi.w-r.x].covered = false
+	}
+	return sub
+}
+
+// DetectEllipses finds regions of connected pixels with the same color that are shaped like
+// filled circles or ellipses, like dots and buttons, and draws each of them with a single
+// <circle> or <ellipse> element. The tolerance is the fraction of the pixels in the bounding box
+// of a region that may differ from the rasterized shape, where 0 means an exact match. The pixels
+// that differ are drawn with rectangles, on top of the shape. Only regions that are at least
+// minSize pixels wide and high are used, and only if the shape is smaller than the rectangles.
+// This must be done before covering the rest of the image with boxes.
+func (pi *PixelImage) DetectEllipses(tolerance float64, minSize int) (*ShapeStats, error) {
+	defer pi.timePhase("shapes")()
+	if tolerance < 0 || tolerance > 1 {
+		return nil, errors.New("the shape tolerance must be from 0 to 1")
+	}
+	if minSize < 2 {
+		return nil, errors.New("the minimum shape size must be at least 2")
+	}
+	stats := &ShapeStats{}
+	for _, r := range pi.regions() {
+		if r.w < minSize || r.h < minSize {
+			continue
+		}
+		e, mismatches, ok := pi.fitEllipse(r)
+		if !ok || float64(mismatches) > tolerance*float64(r.w*r.h) {
+			continue
+		}
+
+		p := pi.pixels[r.pixels[0]]
+		fill := pi.fillString(p.r, p.g, p.b)
+		var element string
+		if e.rx == e.ry {
+			element = fmt.Sprintf("<circle cx=\"%s\" cy=\"%s\" r=\"%s\" fill=\"%s\"/>",
+				formatNumber(e.cx), formatNumber(e.cy), formatNumber(e.rx), fill)
+		} else {
+			element = fmt.Sprintf("<ellipse cx=\"%s\" cy=\"%s\" rx=\"%s\" ry=\"%s\" fill=\"%s\"/>",
+				formatNumber(e.cx), formatNumber(e.cy), formatNumber(e.rx), formatNumber(e.ry), fill)
+		}
+
+		// Only use the shape if it is smaller than the rectangles, including the rectangles
+		// that are needed for the pixels that differ. Boxes from the rest of the image often
+		// extend into the region anyway, so require a good margin.
+		sub := pi.regionImage(r)
+		sub.coverBoxes()
+		if 2*(len(element)+mismatches*len(`<rect x="0" y="0" width="1" height="1"/>`)) >= len(encodeRects(sub.rects, pi.encoding, pi.colorOptimize)) {
+			continue
+		}
+
+		pi.prelude.WriteString(element)
+		for _, i := range r.pixels {
+			if e.inside(i%pi.w, i/pi.w) {
+				pi.pixels[i].covered = true
+				stats.Pixels++
+			}
+		}
+		if e.rx == e.ry {
+			stats.Circles++
+		} else {
+			stats.Ellipses++
+		}
+	}
+	if pi.verbose {
+		fmt.Println(stats)
+	}
+	return stats, nil
+}
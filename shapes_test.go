@@ -0,0 +1,71 @@
+package png2svg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// shapesMask is a red circle and a blue ellipse on a white background, drawn by hand,
+// so that the test does not depend on how the shapes are detected
+var shapesMask = []string{
+	"................................",
+	"......................bbbb......",
+	"......rrrr...........bbbbbb.....",
+	"....rrrrrrrr........bbbbbbbb....",
+	"...rrrrrrrrrr.......bbbbbbbb....",
+	"...rrrrrrrrrr......bbbbbbbbbb...",
+	"..rrrrrrrrrrrr.....bbbbbbbbbb...",
+	"..rrrrrrrrrrrr.....bbbbbbbbbb...",
+	"..rrrrrrrrrrrr.....bbbbbbbbbb...",
+	"..rrrrrrrrrrrr.....bbbbbbbbbb...",
+	"...rrrrrrrrrr......bbbbbbbbbb...",
+	"...rrrrrrrrrr.......bbbbbbbb....",
+	"....rrrrrrrr........bbbbbbbb....",
+	"......rrrr...........bbbbbb.....",
+	"......................bbbb......",
+	"................................",
+}
+
+func TestDetectEllipses(t *testing.T) {
+	colors := map[byte]color.NRGBA{'.': {0xff, 0xff, 0xff, 0xff}, 'r': {0xff, 0, 0, 0xff}, 'b': {0, 0, 0xff, 0xff}}
+	img := image.NewNRGBA(image.Rect(0, 0, len(shapesMask[0]), len(shapesMask)))
+	for y, row := range shapesMask {
+		for x := range row {
+			img.SetNRGBA(x, y, colors[row[x]])
+		}
+	}
+
+	pi := NewPixelImage(img, false)
+	stats, err := pi.DetectEllipses(0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Circles != 1 || stats.Ellipses != 1 {
+		t.Fatalf("Unexpected shape statistics: %s", stats)
+	}
+	pi.coverBoxes()
+	svgDocument := pi.Bytes()
+	for _, element := range []string{`<circle cx="8" cy="8" r="6" fill="red"/>`, `<ellipse cx="24" cy="8" rx="5" ry="7" fill="#00f"/>`} {
+		if !bytes.Contains(svgDocument, []byte(element)) {
+			t.Errorf("Expected %s in:\n%s", element, svgDocument)
+		}
+	}
+	rendered, err := Rasterize(svgDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if differences, err := CompareImages(img, rendered, 0); err != nil || differences != 0 {
+		t.Errorf("Expected the shapes to be drawn exactly, got %d differences (%v)", differences, err)
+	}
+
+	// A square is not a circle
+	pi = NewPixelImage(image.NewNRGBA(image.Rect(0, 0, 8, 8)), false)
+	for _, p := range pi.pixels {
+		p.a, p.covered = 0xff, false
+	}
+	if stats, err := pi.DetectEllipses(0.05, 3); err != nil || stats.Circles+stats.Ellipses != 0 {
+		t.Errorf("Expected no shapes, got %v, %v", stats, err)
+	}
+}
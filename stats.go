@@ -0,0 +1,108 @@
+package png2svg
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Pass contains the size of the SVG document before and after one of the passes in Bytes
+type Pass struct {
+	Name     string        `json:"name"`
+	Before   int           `json:"before"`
+	After    int           `json:"after"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Phase contains the time that was spent in one phase of the conversion
+type Phase struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// Stats contains statistics about a conversion, for reports and dashboards
+type Stats struct {
+	Width        int     `json:"width"`
+	Height       int     `json:"height"`
+	UniqueColors int     `json:"unique_colors"` // the number of unique colors of the pixels that are not transparent
+	Rects        int     `json:"rects"`         // the number of rectangles that have been placed
+	SinglePixel  int     `json:"single_pixel"`  // the number of rectangles that cover a single pixel
+	Expanded     int     `json:"expanded"`      // the number of rectangles that cover more than one pixel
+	Groups       int     `json:"groups"`        // the number of <g> elements that group rectangles by color
+	Size         int     `json:"size"`          // the size of the SVG document, in bytes, before it is compressed
+	Passes       []Pass  `json:"passes"`        // the passes of the last call to Bytes
+	Phases       []Phase `json:"phases"`        // the phases of the conversion, in the order they were first recorded
+}
+
+// String returns a short summary of the statistics
+func (s *Stats) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%dx%d pixels with %d unique colors, %d rectangles (%d 1x1 and %d expanded) in %d groups, %d bytes",
+		s.Width, s.Height, s.UniqueColors, s.Rects, s.SinglePixel, s.Expanded, s.Groups, s.Size)
+	for _, p := range s.Passes {
+		fmt.Fprintf(&sb, "\n  pass %s: %d -> %d bytes in %s", p.Name, p.Before, p.After, p.Duration)
+	}
+	for _, p := range s.Phases {
+		fmt.Fprintf(&sb, "\n  phase %s: %s", p.Name, p.Duration)
+	}
+	return sb.String()
+}
+
+// RecordPhase adds the time that was spent in a phase of the conversion to the statistics,
+// for phases that are not a part of the PixelImage methods, like reading the PNG image.
+// The time is added to the phase with the same name, if it has been recorded before.
+func (pi *PixelImage) RecordPhase(name string, d time.Duration) {
+	for i, p := range pi.phases {
+		if p.Name == name {
+			pi.phases[i].Duration += d
+			return
+		}
+	}
+	pi.phases = append(pi.phases, Phase{name, d})
+}
+
+// timePhase starts timing a phase of the conversion, and returns a function that records it
+func (pi *PixelImage) timePhase(name string) func() {
+	start := time.Now()
+	return func() {
+		pi.RecordPhase(name, time.Since(start))
+	}
+}
+
+// pass records the size of the SVG document after a pass in Bytes, and starts the next pass
+func (pi *PixelImage) pass(name string, before []byte, after []byte, start *time.Time) {
+	now := time.Now()
+	pi.passes = append(pi.passes, Pass{name, len(before), len(after), now.Sub(*start)})
+	*start = now
+}
+
+// Stats returns statistics about the conversion so far. The sizes of the SVG document are
+// from the last call to Bytes or WriteSVG.
+func (pi *PixelImage) Stats() *Stats {
+	s := &Stats{
+		Width:  pi.w,
+		Height: pi.h,
+		Rects:  len(pi.rects),
+		Groups: pi.groups,
+		Passes: append([]Pass(nil), pi.passes...),
+		Phases: append([]Phase(nil), pi.phases...),
+	}
+	if len(s.Passes) > 0 {
+		s.Size = s.Passes[len(s.Passes)-1].After
+	}
+	for _, r := range pi.rects {
+		if r.W == 1 && r.H == 1 {
+			s.SinglePixel++
+		} else {
+			s.Expanded++
+		}
+	}
+	colors := make(map[[4]int]struct{})
+	for _, p := range pi.pixels {
+		if p.a > 0 {
+			colors[[4]int{p.r, p.g, p.b, p.a}] = struct{}{}
+		}
+	}
+	s.UniqueColors = len(colors)
+	return s
+}
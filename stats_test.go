@@ -0,0 +1,52 @@
+package png2svg
+
+import (
+	"image"
+	"image/color"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 2))
+	for x := 0; x < 4; x++ {
+		img.SetNRGBA(x, 0, color.NRGBA{0xff, 0, 0, 0xff})
+	}
+	img.SetNRGBA(0, 1, color.NRGBA{0, 0, 0xff, 0xff})
+	img.SetNRGBA(1, 1, color.NRGBA{0, 0, 0xff, 0x80})
+
+	pi := NewPixelImage(img, false)
+	pi.coverBoxes()
+	pi.RecordPhase("read", time.Millisecond)
+	pi.RecordPhase("read", time.Millisecond)
+	svgDocument := pi.Bytes()
+
+	stats := pi.Stats()
+	if stats.Width != 4 || stats.Height != 2 || stats.UniqueColors != 3 {
+		t.Errorf("Unexpected image statistics: %s", stats)
+	}
+	if stats.Rects != 3 || stats.SinglePixel != 2 || stats.Expanded != 1 {
+		t.Errorf("Unexpected rectangle statistics: %s", stats)
+	}
+	if stats.Size != len(svgDocument) {
+		t.Errorf("Expected the size to be %d bytes, got %d", len(svgDocument), stats.Size)
+	}
+	for i := 1; i < len(stats.Passes); i++ {
+		if stats.Passes[i].Before != stats.Passes[i-1].After {
+			t.Errorf("The %s pass does not start where the %s pass ended", stats.Passes[i].Name, stats.Passes[i-1].Name)
+		}
+	}
+	phases := make(map[string]time.Duration)
+	for _, p := range stats.Phases {
+		phases[p.Name] = p.Duration
+	}
+	if _, ok := phases["pixels"]; !ok {
+		t.Error("The pixels phase is missing")
+	}
+	if _, ok := phases["bytes"]; !ok {
+		t.Error("The bytes phase is missing")
+	}
+	if phases["read"] != 2*time.Millisecond {
+		t.Errorf("Expected the read phase to take 2ms, got %s", phases["read"])
+	}
+}
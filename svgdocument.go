@@ -0,0 +1,77 @@
+package png2svg
+
+import "bytes"
+
+// insertBeforeClosingTag inserts the given elements at the end of the root <svg> tag
+func insertBeforeClosingTag(svgDocument, elements []byte) []byte {
+	if len(elements) == 0 {
+		return svgDocument
+	}
+	closing := []byte("</svg>")
+	if i := bytes.LastIndex(svgDocument, closing); i >= 0 {
+		var buf bytes.Buffer
+		buf.Write(svgDocument[:i])
+		buf.Write(elements)
+		buf.Write(svgDocument[i:])
+		return buf.Bytes()
+	}
+	// The <svg> tag has no children and is rendered as <svg .../>
+	trimmed := bytes.TrimSuffix(bytes.TrimRight(svgDocument, " \n"), []byte("/>"))
+	trimmed = bytes.TrimRight(trimmed, " ")
+	var buf bytes.Buffer
+	buf.Write(trimmed)
+	buf.WriteByte('>')
+	buf.Write(elements)
+	buf.Write(closing)
+	return buf.Bytes()
+}
+
+// insertAfterOpeningTag inserts the given elements at the start of the root <svg> tag,
+// before any other elements
+func insertAfterOpeningTag(svgDocument, elements []byte) []byte {
+	if len(elements) == 0 {
+		return svgDocument
+	}
+	start := bytes.Index(svgDocument, []byte("<svg"))
+	if start < 0 {
+		return svgDocument
+	}
+	end := bytes.IndexByte(svgDocument[start:], '>')
+	if end < 0 {
+		return svgDocument
+	}
+	end += start
+	if svgDocument[end-1] == '/' {
+		// The <svg> tag has no children and is rendered as <svg .../>
+		return insertBeforeClosingTag(svgDocument, elements)
+	}
+	var buf bytes.Buffer
+	buf.Write(svgDocument[:end+1])
+	buf.Write(elements)
+	buf.Write(svgDocument[end+1:])
+	return buf.Bytes()
+}
+
+// removeRootAttribute removes the attribute with the given name from the root <svg> tag
+func removeRootAttribute(svgDocument []byte, name string) []byte {
+	start := bytes.Index(svgDocument, []byte("<svg"))
+	if start < 0 {
+		return svgDocument
+	}
+	end := bytes.IndexByte(svgDocument[start:], '>')
+	if end < 0 {
+		return svgDocument
+	}
+	end += start
+	i := bytes.Index(svgDocument[start:end], []byte(" "+name+"=\""))
+	if i < 0 {
+		return svgDocument
+	}
+	i += start
+	valueStart := i + len(name) + 3
+	j := bytes.IndexByte(svgDocument[valueStart:end], '"')
+	if j < 0 {
+		return svgDocument
+	}
+	return append(svgDocument[:i:i], svgDocument[valueStart+j+1:]...)
+}
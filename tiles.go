@@ -0,0 +1,246 @@
+package png2svg
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+// TileStats contains information about the repeated tiles that were found by DeduplicateTiles
+type TileStats struct {
+	TileWidth  int // the width of the tiles that were examined
+	TileHeight int // the height of the tiles that were examined
+	Tiles      int // the number of tiles that were examined
+	Unique     int // the number of distinct tiles that occur more than once, and are placed in <defs>
+	Instances  int // the number of <use> elements that draws the repeated tiles
+	Pixels     int // the number of pixels that are drawn by <use> elements instead of rectangles
+}
+
+// String returns a short summary of the deduplication
+func (ts *TileStats) String() string {
+	return fmt.Sprintf("%dx%d tiles: %d examined, %d unique tiles drawn %d times, covering %d pixels",
+		ts.TileWidth, ts.TileHeight, ts.Tiles, ts.Unique, ts.Instances, ts.Pixels)
+}
+
+// tile is a position in the image where a tile starts
+type tile struct {
+	x, y int
+}
+
+// tileHash returns a hash of the colors in the tile at the given position.
+// All transparent pixels are considered equal.
+func (pi *PixelImage) tileHash(t tile, tw, th int) uint64 {
+	h := fnv.New64a()
+	buf := make([]byte, 4)
+	for y := t.y; y < t.y+th; y++ {
+		for x := t.x; x < t.x+tw; x++ {
+			p := pi.pixels[y*pi.w+x]
+			if p.a == 0 {
+				buf[0], buf[1], buf[2], buf[3] = 0, 0, 0, 0
+			} else {
+				buf[0], buf[1], buf[2], buf[3] = byte(p.r), byte(p.g), byte(p.b), byte(p.a)
+			}
+			h.Write(buf)
+		}
+	}
+	return h.Sum64()
+}
+
+// sameTile checks if the two tiles have exactly the same colors
+func (pi *PixelImage) sameTile(a, b tile, tw, th int) bool {
+	for y := 0; y < th; y++ {
+		for x := 0; x < tw; x++ {
+			p := pi.pixels[(a.y+y)*pi.w+a.x+x]
+			q := pi.pixels[(b.y+y)*pi.w+b.x+x]
+			if p.a == 0 && q.a == 0 {
+				continue
+			}
+			if p.r != q.r || p.g != q.g || p.b != q.b || p.a != q.a {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// candidateTile checks if the tile at the given position has no pixels that are covered already,
+// and has more than one color. Tiles with a single color are better drawn with a rectangle.
+func (pi *PixelImage) candidateTile(t tile, tw, th int) bool {
+	var (
+		first  *Pixel
+		single = true
+		opaque = false
+	)
+	for y := t.y; y < t.y+th; y++ {
+		for x := t.x; x < t.x+tw; x++ {
+			p := pi.pixels[y*pi.w+x]
+			if p.a == 0 {
+				single = false
+				continue
+			}
+			if p.covered {
+				return false
+			}
+			opaque = true
+			if first == nil {
+				first = p
+			} else if p.r != first.r || p.g != first.g || p.b != first.b || p.a != first.a {
+				single = false
+			}
+		}
+	}
+	return opaque && !single
+}
+
+// tileImage returns a new PixelImage with the pixels of the tile at the given position,
+// where only the rectangles are recorded
+func (pi *PixelImage) tileImage(t tile, tw, th int) *PixelImage {
+	pixels := make(Pixels, tw*th)
+	for y := 0; y < th; y++ {
+		for x := 0; x < tw; x++ {
+			p := pi.pixels[(t.y+y)*pi.w+t.x+x]
+			pixels[y*tw+x] = &Pixel{x, y, p.r, p.g, p.b, p.a, p.a == 0}
+		}
+	}
+	return &PixelImage{
+		pixels:        pixels,
+		w:             tw,
+		h:             th,
+		colorOptimize: pi.colorOptimize,
+		encoding:      PathEncoding, // only record the rectangles, don't add tags
+	}
+}
+
+// uncoveredSize returns the size of the rectangles that cover the pixels that are not covered yet
+func (pi *PixelImage) uncoveredSize() int {
+	pixels := make(Pixels, len(pi.pixels))
+	for i, p := range pi.pixels {
+		pixels[i] = &Pixel{p.x, p.y, p.r, p.g, p.b, p.a, p.covered}
+	}
+	sub := &PixelImage{
+		pixels:        pixels,
+		w:             pi.w,
+		h:             pi.h,
+		colorOptimize: pi.colorOptimize,
+		encoding:      PathEncoding, // only record the rectangles, don't add tags
+	}
+	sub.coverBoxes()
+	return len(encodeRects(sub.rects, pi.encoding, pi.colorOptimize))
+}
+
+// DeduplicateTiles divides the image into tiles of the given size, and finds tiles
+// that occur more than once. Each distinct repeated tile is drawn once, inside <defs>,
+// and then placed with a <use> element for each occurrence. The pixels of the
+// repeated tiles are marked as covered. This must be done before covering the rest of
+// the image with boxes. Only tiles that are aligned to a grid of the given size, starting
+// in the upper left corner, are found. Tiles at the right and bottom edge that are not
+// complete are skipped. The tiles are only used if the SVG document gets smaller.
+func (pi *PixelImage) DeduplicateTiles(tileWidth, tileHeight int) (*TileStats, error) {
+	defer pi.timePhase("tiles")()
+	if tileWidth < 1 || tileHeight < 1 {
+		return nil, errors.New("the tile size must be at least 1x1")
+	}
+	stats := &TileStats{TileWidth: tileWidth, TileHeight: tileHeight}
+
+	// Group the tiles by hash, keeping the order they were found in
+	var (
+		hashes []uint64
+		groups = make(map[uint64][]tile)
+	)
+	for y := 0; y+tileHeight <= pi.h; y += tileHeight {
+		for x := 0; x+tileWidth <= pi.w; x += tileWidth {
+			t := tile{x, y}
+			if !pi.candidateTile(t, tileWidth, tileHeight) {
+				continue
+			}
+			stats.Tiles++
+			h := pi.tileHash(t, tileWidth, tileHeight)
+			if _, ok := groups[h]; !ok {
+				hashes = append(hashes, h)
+			}
+			groups[h] = append(groups[h], t)
+		}
+	}
+
+	// Remember the state, so that the tiles can be removed again if they do not make the document smaller
+	var (
+		before              = -1
+		defsLen, preludeLen = pi.defs.Len(), pi.prelude.Len()
+		tileCount, xlink    = pi.tileCount, pi.xlink
+		covered             []*Pixel
+	)
+	for _, h := range hashes {
+		// Only keep the tiles that are identical to the first one, in case of hash collisions
+		first := groups[h][0]
+		var repeats []tile
+		for _, t := range groups[h] {
+			if pi.sameTile(first, t, tileWidth, tileHeight) {
+				repeats = append(repeats, t)
+			}
+		}
+		if len(repeats) < 2 {
+			continue
+		}
+
+		// Skip the tile if drawing it once and placing it is not smaller than drawing it every time
+		id := fmt.Sprintf("t%d", pi.tileCount)
+		sub := pi.tileImage(first, tileWidth, tileHeight)
+		sub.coverBoxes()
+		contents := encodeRects(sub.rects, pi.encoding, pi.colorOptimize)
+		definition := fmt.Sprintf("<g id=\"%s\">%s</g>", id, contents)
+		use := fmt.Sprintf("<use xlink:href=\"#%s\" x=\"%d\" y=\"%d\"/>", id, pi.w, pi.h)
+		if len(definition)+len(repeats)*len(use) >= len(repeats)*len(contents) {
+			continue
+		}
+		if before < 0 {
+			before = pi.uncoveredSize()
+		}
+
+		// Draw the tile once, inside <defs>
+		pi.tileCount++
+		pi.defs.WriteString(definition)
+
+		// Place the tile at every position where it occurs
+		for _, t := range repeats {
+			fmt.Fprintf(&pi.prelude, "<use xlink:href=\"#%s\" x=\"%d\" y=\"%d\"/>", id, t.x, t.y)
+			for y := t.y; y < t.y+tileHeight; y++ {
+				for x := t.x; x < t.x+tileWidth; x++ {
+					if p := pi.pixels[y*pi.w+x]; !p.covered {
+						p.covered = true
+						covered = append(covered, p)
+						stats.Pixels++
+					}
+				}
+			}
+		}
+		pi.xlink = true
+		stats.Unique++
+		stats.Instances += len(repeats)
+	}
+
+	// Compare the real sizes, since the rectangles around the tiles can no longer be merged with
+	// the rectangles inside them, and the tiles need <defs> and the xlink namespace
+	if stats.Unique > 0 {
+		after := pi.uncoveredSize() + pi.defs.Len() - defsLen + pi.prelude.Len() - preludeLen
+		if defsLen == 0 {
+			after += len("<defs></defs>")
+		}
+		if !xlink {
+			after += len(` xmlns:xlink="http://www.w3.org/1999/xlink"`)
+		}
+		if after >= before {
+			pi.defs.Truncate(defsLen)
+			pi.prelude.Truncate(preludeLen)
+			pi.tileCount, pi.xlink = tileCount, xlink
+			for _, p := range covered {
+				p.covered = false
+			}
+			stats.Unique, stats.Instances, stats.Pixels = 0, 0, 0
+		}
+	}
+
+	if pi.verbose {
+		fmt.Println(stats)
+	}
+	return stats, nil
+}
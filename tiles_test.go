@@ -0,0 +1,62 @@
+package png2svg
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"testing"
+)
+
+func TestDeduplicateTiles(t *testing.T) {
+	glenda, err := ReadPNG("img/glenda.png", false)
+	if err != nil {
+		t.Fatalf("Failed to read PNG file: %v", err)
+	}
+
+	// Create a sprite sheet with the same 64x64 sprite four times
+	sheet := image.NewNRGBA(image.Rect(0, 0, 128, 128))
+	for _, p := range []image.Point{{0, 0}, {64, 0}, {0, 64}, {64, 64}} {
+		draw.Draw(sheet, image.Rect(p.X, p.Y, p.X+64, p.Y+64), glenda, glenda.Bounds().Min, draw.Src)
+	}
+
+	pi := NewPixelImage(sheet, false)
+	stats, err := pi.DeduplicateTiles(64, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Tiles != 4 || stats.Unique != 1 || stats.Instances != 4 || stats.Pixels != 4*64*64 {
+		t.Errorf("Unexpected tile statistics: %s", stats)
+	}
+	pi.coverBoxes()
+
+	svgDocument := pi.Bytes()
+	if n := bytes.Count(svgDocument, []byte("<use ")); n != 4 {
+		t.Errorf("Expected 4 <use> elements, got %d", n)
+	}
+	if !bytes.Contains(svgDocument, []byte(`xmlns:xlink="http://www.w3.org/1999/xlink"`)) {
+		t.Error("The xlink namespace is missing")
+	}
+	rendered, err := Rasterize(svgDocument)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if differences, err := CompareImages(sheet, rendered, 0); err != nil || differences != 0 {
+		t.Errorf("Expected the tiles to be drawn exactly, got %d differences (%v)", differences, err)
+	}
+
+	// Tiles are not used if they would make the SVG document larger
+	spaceships, err := ReadPNG("img/spaceships.png", false)
+	if err != nil {
+		t.Fatalf("Failed to read PNG file: %v", err)
+	}
+	plain := NewPixelImage(spaceships, false)
+	plain.coverBoxes()
+	pi = NewPixelImage(spaceships, false)
+	if stats, err = pi.DeduplicateTiles(8, 8); err != nil {
+		t.Fatal(err)
+	}
+	pi.coverBoxes()
+	if svgDocument, plainDocument := pi.Bytes(), plain.Bytes(); len(svgDocument) > len(plainDocument) {
+		t.Errorf("Expected the tiles to not make the SVG document larger, got %d bytes, compared to %d bytes (%s)", len(svgDocument), len(plainDocument), stats)
+	}
+}
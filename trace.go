@@ -0,0 +1,416 @@
+package png2svg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// TraceOptions contains the settings for Trace
+type TraceOptions struct {
+	// Tolerance is the largest distance, in pixels, that the simplified outline
+	// may be from the traced pixel boundary (Ramer-Douglas-Peucker)
+	Tolerance float64
+	// CornerThreshold is an angle in degrees. Where the simplified outline turns
+	// more than this, a sharp corner is kept instead of a smooth curve.
+	CornerThreshold float64
+	// Smoothing decides how round the curves are. 0 gives straight lines,
+	// 1 gives curves that pass smoothly through the points of the simplified outline.
+	Smoothing float64
+}
+
+// NewTraceOptions returns TraceOptions with default values
+func NewTraceOptions() *TraceOptions {
+	return &TraceOptions{
+		Tolerance:       1.0,
+		CornerThreshold: 60,
+		Smoothing:       1.0,
+	}
+}
+
+// point is a position on the grid of pixel corners
+type point struct {
+	x, y float64
+}
+
+// edge is a directed pixel edge between two pixel corners, on the boundary of a region
+type edge struct {
+	x0, y0, x1, y1 int
+}
+
+// traceContours finds the outlines of the pixels that are set in the given mask.
+// Outer outlines are clockwise and holes are counter-clockwise (with y pointing down),
+// so that they can be filled with the nonzero fill rule. Pixels that only touch
+// diagonally are traced as separate regions.
+func traceContours(mask []bool, w, h int) [][]point {
+	inside := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < w && y < h && mask[y*w+x]
+	}
+
+	// Find all edges between pixels inside and outside of the mask,
+	// directed so that the inside is on the right hand side
+	var edges []edge
+	outgoing := make(map[[2]int][]int)
+	add := func(e edge) {
+		outgoing[[2]int{e.x0, e.y0}] = append(outgoing[[2]int{e.x0, e.y0}], len(edges))
+		edges = append(edges, e)
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !mask[y*w+x] {
+				continue
+			}
+			if !inside(x, y-1) {
+				add(edge{x, y, x + 1, y})
+			}
+			if !inside(x+1, y) {
+				add(edge{x + 1, y, x + 1, y + 1})
+			}
+			if !inside(x, y+1) {
+				add(edge{x + 1, y + 1, x, y + 1})
+			}
+			if !inside(x-1, y) {
+				add(edge{x, y + 1, x, y})
+			}
+		}
+	}
+
+	// Link the edges together to closed loops
+	var (
+		loops [][]point
+		used  = make([]bool, len(edges))
+	)
+	for start := range edges {
+		if used[start] {
+			continue
+		}
+		var loop []point
+		current := start
+		for {
+			used[current] = true
+			e := edges[current]
+			loop = append(loop, point{float64(e.x0), float64(e.y0)})
+			// Where two regions touch diagonally, there are two outgoing edges.
+			// Always turning right keeps the regions apart.
+			candidates := outgoing[[2]int{e.x1, e.y1}]
+			next := candidates[0]
+			for _, candidate := range candidates[1:] {
+				if turnsRight(e, edges[candidate]) {
+					next = candidate
+				}
+			}
+			if next == start || used[next] {
+				break
+			}
+			current = next
+		}
+		loops = append(loops, removeCollinear(loop))
+	}
+	return loops
+}
+
+// turnsRight checks if going from edge a to edge b is a right turn (with y pointing down)
+func turnsRight(a, b edge) bool {
+	ax, ay := a.x1-a.x0, a.y1-a.y0
+	bx, by := b.x1-b.x0, b.y1-b.y0
+	return ax*by-ay*bx > 0
+}
+
+// removeCollinear removes the points that are on a straight line between their neighbors
+func removeCollinear(loop []point) []point {
+	n := len(loop)
+	if n < 3 {
+		return loop
+	}
+	var result []point
+	for i, p := range loop {
+		prev := loop[(i+n-1)%n]
+		next := loop[(i+1)%n]
+		if (p.x-prev.x)*(next.y-p.y)-(p.y-prev.y)*(next.x-p.x) != 0 {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// distanceToSegment returns the distance from p to the line segment from a to b
+func distanceToSegment(p, a, b point) float64 {
+	dx, dy := b.x-a.x, b.y-a.y
+	lengthSquared := dx*dx + dy*dy
+	if lengthSquared == 0 {
+		return math.Hypot(p.x-a.x, p.y-a.y)
+	}
+	t := math.Max(0, math.Min(1, ((p.x-a.x)*dx+(p.y-a.y)*dy)/lengthSquared))
+	return math.Hypot(p.x-(a.x+t*dx), p.y-(a.y+t*dy))
+}
+
+// simplifyOpen simplifies a polyline with the Ramer-Douglas-Peucker algorithm.
+// The first and last points are always kept.
+func simplifyOpen(points []point, tolerance float64) []point {
+	if len(points) < 3 {
+		return points
+	}
+	var (
+		first, last = points[0], points[len(points)-1]
+		maxDistance float64
+		index       int
+	)
+	for i := 1; i < len(points)-1; i++ {
+		if d := distanceToSegment(points[i], first, last); d > maxDistance {
+			maxDistance, index = d, i
+		}
+	}
+	if maxDistance <= tolerance {
+		return []point{first, last}
+	}
+	left := simplifyOpen(points[:index+1], tolerance)
+	right := simplifyOpen(points[index:], tolerance)
+	return append(left[:len(left)-1], right...)
+}
+
+// simplifyClosed simplifies a closed loop with the Ramer-Douglas-Peucker algorithm,
+// by splitting it in two at the point that is farthest away from the first point
+func simplifyClosed(loop []point, tolerance float64) []point {
+	if len(loop) < 4 {
+		return loop
+	}
+	var (
+		maxDistance float64
+		index       int
+	)
+	for i, p := range loop {
+		if d := math.Hypot(p.x-loop[0].x, p.y-loop[0].y); d > maxDistance {
+			maxDistance, index = d, i
+		}
+	}
+	first := simplifyOpen(loop[:index+1], tolerance)
+	second := simplifyOpen(append(append([]point{}, loop[index:]...), loop[0]), tolerance)
+	simplified := append(first[:len(first)-1], second[:len(second)-1]...)
+	if len(simplified) < 3 {
+		// Thin regions would disappear, keep them as they are
+		return loop
+	}
+	return simplified
+}
+
+// pathWriter writes path data with relative commands, rounding all positions
+// to two decimals before calculating the relative distances, so that errors don't add up
+type pathWriter struct {
+	buf          bytes.Buffer
+	scale        float64
+	lastx, lasty int // the current position, in hundredths
+	lastCommand  byte
+}
+
+// round converts a coordinate to hundredths of the output unit
+func (pw *pathWriter) round(x float64) int {
+	return int(math.Round(x * pw.scale * 100))
+}
+
+// command writes a path command, followed by the given points, relative to the current position.
+// The command letter is left out if it is the same as the previous one.
+func (pw *pathWriter) command(c byte, points ...point) {
+	separate := true
+	if c != pw.lastCommand || c == 'm' {
+		pw.buf.WriteByte(c)
+		separate = false
+	}
+	pw.lastCommand = c
+	var x, y int
+	for _, p := range points {
+		x, y = pw.round(p.x), pw.round(p.y)
+		pw.number(x-pw.lastx, separate)
+		pw.number(y-pw.lasty, true)
+		separate = true
+	}
+	// Relative coordinates in a command are relative to where the command starts
+	pw.lastx, pw.lasty = x, y
+}
+
+// number writes a number of hundredths, with a separating space before it if needed
+func (pw *pathWriter) number(n int, separate bool) {
+	if separate && n >= 0 {
+		pw.buf.WriteByte(' ')
+	}
+	pw.buf.WriteString(strconv.FormatFloat(float64(n)/100, 'f', -1, 64))
+}
+
+// close closes the current subpath, which moves the current position to the start of it
+func (pw *pathWriter) close(start point) {
+	pw.buf.WriteByte('z')
+	pw.lastCommand = 'z'
+	pw.lastx, pw.lasty = pw.round(start.x), pw.round(start.y)
+}
+
+// writeLoop writes a closed loop as a subpath, where the points are connected by
+// cubic Bézier curves, except at sharp corners where straight lines are used
+func (pw *pathWriter) writeLoop(loop []point, opts *TraceOptions) {
+	n := len(loop)
+	if n < 3 {
+		return
+	}
+	corner := make([]bool, n)
+	for i, p := range loop {
+		prev, next := loop[(i+n-1)%n], loop[(i+1)%n]
+		a1 := math.Atan2(p.y-prev.y, p.x-prev.x)
+		a2 := math.Atan2(next.y-p.y, next.x-p.x)
+		turn := math.Abs(math.Remainder(a2-a1, 2*math.Pi)) * 180 / math.Pi
+		corner[i] = turn > opts.CornerThreshold
+	}
+	// The tangent at a smooth point is parallel to the line between its neighbors.
+	// At a corner, the tangent follows the line segment.
+	tangent := func(i int, toward point) point {
+		p := loop[i]
+		if corner[i] {
+			return unit(point{toward.x - p.x, toward.y - p.y})
+		}
+		prev, next := loop[(i+n-1)%n], loop[(i+1)%n]
+		return unit(point{next.x - prev.x, next.y - prev.y})
+	}
+	pw.command('m', loop[0])
+	for i := 0; i < n; i++ {
+		j := (i + 1) % n
+		p, q := loop[i], loop[j]
+		if opts.Smoothing <= 0 || (corner[i] && corner[j]) {
+			if j != 0 {
+				// The last line is drawn by closing the subpath
+				pw.command('l', q)
+			}
+			continue
+		}
+		arm := math.Hypot(q.x-p.x, q.y-p.y) * opts.Smoothing / 3
+		t1 := tangent(i, q)
+		t2 := tangent(j, p)
+		if corner[j] {
+			// The tangent should point backwards, from q towards p
+			t2 = point{-t2.x, -t2.y}
+		}
+		c1 := point{p.x + t1.x*arm, p.y + t1.y*arm}
+		c2 := point{q.x - t2.x*arm, q.y - t2.y*arm}
+		pw.command('c', c1, c2, q)
+	}
+	pw.close(loop[0])
+}
+
+// unit returns the given vector with a length of 1
+func unit(v point) point {
+	length := math.Hypot(v.x, v.y)
+	if length == 0 {
+		return v
+	}
+	return point{v.x / length, v.y / length}
+}
+
+// maxTraceColors is the largest number of colors that can be traced, since each color is
+// traced as a separate layer. Images with more colors, like photos, must be reduced first.
+const maxTraceColors = 1024
+
+// traceLayers traces the regions of a grid of labels, where each label is an index into fills
+// and -1 means transparent. The labels are drawn as layers, from the largest area to the smallest.
+// Each layer also covers the pixels of the layers that are drawn on top of it, so that there
+// are no gaps between the smoothed outlines. Coordinates are multiplied with scale.
+// If editable is true, each path gets an id, an inkscape:label and a data-color attribute.
+func traceLayers(labels []int, w, h int, fills []string, opts *TraceOptions, scale float64, editable bool) []byte {
+	// Find the pixels of each label, and start with a mask of all the labelled pixels
+	var (
+		pixels = make([][]int, len(fills))
+		mask   = make([]bool, len(labels))
+	)
+	for i, label := range labels {
+		if label >= 0 {
+			pixels[label] = append(pixels[label], i)
+			mask[i] = true
+		}
+	}
+
+	// Sort the labels by area, largest first
+	order := make([]int, 0, len(fills))
+	for label := range pixels {
+		if len(pixels[label]) > 0 {
+			order = append(order, label)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(pixels[order[i]]) > len(pixels[order[j]])
+	})
+
+	var buf bytes.Buffer
+	for _, label := range order {
+		// The mask contains the pixels of this layer and of all the layers on top of it
+		pw := &pathWriter{scale: scale}
+		for _, loop := range traceContours(mask, w, h) {
+			pw.writeLoop(simplifyClosed(loop, opts.Tolerance), opts)
+		}
+		for _, i := range pixels[label] {
+			mask[i] = false
+		}
+		if pw.buf.Len() == 0 {
+			continue
+		}
+		buf.WriteString("<path")
+		if editable {
+			buf.WriteString(editableAttributes(fills[label]))
+		}
+		fmt.Fprintf(&buf, " fill=\"%s\" d=\"", fills[label])
+		buf.Write(pw.buf.Bytes())
+		buf.WriteString("\"/>")
+	}
+	return buf.Bytes()
+}
+
+// labelPixels returns a grid of labels for all pixels that are not covered yet, together with
+// the fill color of each label. Covered pixels get the label -1.
+func (pi *PixelImage) labelPixels() ([]int, []string) {
+	var (
+		labels = make([]int, len(pi.pixels))
+		fills  []string
+		index  = make(map[string]int)
+	)
+	for i, p := range pi.pixels {
+		if p.covered {
+			labels[i] = -1
+			continue
+		}
+		fill := pi.fillString(p.r, p.g, p.b)
+		label, ok := index[fill]
+		if !ok {
+			label = len(fills)
+			index[fill] = label
+			fills = append(fills, fill)
+		}
+		labels[i] = label
+	}
+	return labels, fills
+}
+
+// Trace draws all pixels that are not covered yet with smooth <path> elements instead of
+// rectangles, one per color, by tracing the outlines of the regions of each color,
+// simplifying them and connecting the points with cubic Bézier curves. This is better suited
+// for logos and scanned images than for pixel art. All pixels are marked as covered.
+func (pi *PixelImage) Trace(opts *TraceOptions) error {
+	defer pi.timePhase("trace")()
+	if opts == nil {
+		opts = NewTraceOptions()
+	}
+	if opts.Tolerance < 0 || opts.Smoothing < 0 {
+		return errors.New("the tolerance and the smoothing can not be negative")
+	}
+	if pi.verbose {
+		fmt.Print("Tracing...")
+	}
+	labels, fills := pi.labelPixels()
+	if len(fills) > maxTraceColors {
+		return fmt.Errorf("can not trace %d colors, the maximum is %d, reduce the number of colors first", len(fills), maxTraceColors)
+	}
+	pi.elements.Write(traceLayers(labels, pi.w, pi.h, fills, opts, 1, pi.editable))
+	for _, p := range pi.pixels {
+		p.covered = true
+	}
+	if pi.verbose {
+		fmt.Printf("ok (%d colors)\n", len(fills))
+	}
+	return nil
+}
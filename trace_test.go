@@ -0,0 +1,86 @@
+package png2svg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// signedArea returns twice the signed area of a closed loop
+func signedArea(loop []point) float64 {
+	var sum float64
+	for i, p := range loop {
+		q := loop[(i+1)%len(loop)]
+		sum += p.x*q.y - q.x*p.y
+	}
+	return sum
+}
+
+func TestTraceContours(t *testing.T) {
+	// A 3x3 ring has an outer and an inner outline, with opposite orientation
+	ring := []bool{
+		true, true, true,
+		true, false, true,
+		true, true, true,
+	}
+	loops := traceContours(ring, 3, 3)
+	if len(loops) != 2 {
+		t.Fatalf("Expected 2 loops for a ring, got %d", len(loops))
+	}
+	a, b := signedArea(removeCollinear(loops[0])), signedArea(removeCollinear(loops[1]))
+	if a*b >= 0 {
+		t.Errorf("Expected the outlines of a ring to have opposite orientation, got areas %v and %v", a, b)
+	}
+
+	// Pixels that only touch diagonally are traced separately
+	diagonal := []bool{
+		true, false,
+		false, true,
+	}
+	if loops := traceContours(diagonal, 2, 2); len(loops) != 2 {
+		t.Errorf("Expected 2 loops for diagonal pixels, got %d", len(loops))
+	}
+}
+
+func TestTrace(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			c := color.NRGBA{0xff, 0xff, 0xff, 0xff}
+			if x >= 2 && x < 6 && y >= 2 && y < 6 {
+				c = color.NRGBA{0, 0, 0xff, 0xff}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	pi := NewPixelImage(img, false)
+	if err := pi.Trace(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !pi.Done(0, 0) {
+		t.Error("Expected all pixels to be covered after tracing")
+	}
+	svgDocument := pi.Bytes()
+	if n := bytes.Count(svgDocument, []byte("<path ")); n != 2 {
+		t.Errorf("Expected 2 paths, one per color, got %d:\n%s", n, svgDocument)
+	}
+	if err := pi.Trace(&TraceOptions{Tolerance: -1}); err == nil {
+		t.Error("Expected an error for a negative tolerance")
+	}
+
+	// Each color is traced as a layer, so images with too many colors are refused
+	img = image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 4), uint8(y * 4), 0, 0xff})
+		}
+	}
+	pi = NewPixelImage(img, false)
+	if err := pi.Trace(nil); err == nil {
+		t.Errorf("Expected an error for %d colors", 64*64)
+	}
+	if err := pi.TracePixelArt(nil); err == nil {
+		t.Errorf("Expected an error for %d colors", 64*64)
+	}
+}
@@ -0,0 +1,115 @@
+package png2svg
+
+import (
+	"errors"
+	"strconv"
+)
+
+// SetScale sets the displayed size of the SVG image to the size of the PNG image,
+// multiplied with the given scale. The viewBox and the coordinates are kept in pixels.
+func (pi *PixelImage) SetScale(scale float64) error {
+	if scale <= 0 {
+		return errors.New("the scale must be larger than 0")
+	}
+	pi.displayWidth = float64(pi.w) * scale
+	pi.displayHeight = float64(pi.h) * scale
+	pi.stretch = false
+	return nil
+}
+
+// SetPixelSize sets the displayed size of each pixel, which can be different horizontally and
+// vertically. This is useful for showing pixel art that has been converted at its native
+// resolution at the original size. The viewBox and the coordinates are kept in pixels, and the
+// viewer scales the viewBox to the display size, which is the same as a scale transform.
+// If the width and height differ, preserveAspectRatio="none" lets the pixels be stretched.
+func (pi *PixelImage) SetPixelSize(width, height float64) error {
+	if width <= 0 || height <= 0 {
+		return errors.New("the pixel width and height must be larger than 0")
+	}
+	pi.displayWidth = float64(pi.w) * width
+	pi.displayHeight = float64(pi.h) * height
+	// Stretch the image instead of keeping the aspect ratio of the viewBox
+	pi.stretch = width != height
+	return nil
+}
+
+// SetDisplaySize sets the displayed width and height of the SVG image,
+// independently of the viewBox. If either width or height is 0, it is
+// calculated from the other one, keeping the aspect ratio of the PNG image.
+func (pi *PixelImage) SetDisplaySize(width, height float64) error {
+	if width < 0 || height < 0 || (width == 0 && height == 0) {
+		return errors.New("the width or the height must be larger than 0")
+	}
+	if width == 0 {
+		width = height * float64(pi.w) / float64(pi.h)
+	} else if height == 0 {
+		height = width * float64(pi.h) / float64(pi.w)
+	}
+	pi.displayWidth = width
+	pi.displayHeight = height
+	pi.stretch = false
+	return nil
+}
+
+// SetCrispEdges can be used for adding shape-rendering="crispEdges" to the SVG image,
+// which asks the renderer to not anti-alias the edges of the rectangles.
+// This is useful for pixel art, and removes thin lines between the rectangles in some viewers.
+func (pi *PixelImage) SetCrispEdges(enabled bool) {
+	pi.crispEdges = enabled
+}
+
+// SetCompact can be used for leaving out everything that is not strictly needed,
+// like the XML declaration and "px" units, and for writing the rectangles with
+// relative path commands, by selecting PathEncoding. Attributes that are 0, like
+// x="0", are always left out. Like SetEncoding, this must be set before any pixels
+// are covered, and SetEncoding can be used afterwards to keep the <rect> elements.
+func (pi *PixelImage) SetCompact(enabled bool) {
+	pi.compact = enabled
+	if enabled && len(pi.rects) == 0 {
+		pi.encoding = PathEncoding
+	}
+}
+
+// formatNumber formats a float64 with as few digits as possible
+func formatNumber(x float64) []byte {
+	return strconv.AppendFloat(nil, x, 'f', -1, 64)
+}
+
+// sizeAttribute returns a width or height attribute value, with the "px" unit unless compact is enabled
+func (pi *PixelImage) sizeAttribute(x float64) []byte {
+	if pi.compact {
+		return formatNumber(x)
+	}
+	return append(formatNumber(x), "px"...)
+}
+
+// setRootAttributes sets the attributes of the <svg> tag that depend on the selected options
+func (pi *PixelImage) setRootAttributes() {
+	width, height := float64(pi.w), float64(pi.h)
+	if pi.displayWidth > 0 && pi.displayHeight > 0 {
+		width, height = pi.displayWidth, pi.displayHeight
+	}
+	pi.svgTag.AddAttrib("width", pi.sizeAttribute(width))
+	pi.svgTag.AddAttrib("height", pi.sizeAttribute(height))
+	if pi.profile == SVG11Profile {
+		pi.svgTag.AddAttrib("version", []byte("1.1"))
+	} else {
+		pi.svgTag.AddAttrib("version", []byte("1.2"))
+	}
+	if pi.xlink {
+		pi.svgTag.AddAttrib("xmlns:xlink", []byte("http://www.w3.org/1999/xlink"))
+	}
+	if pi.editable {
+		pi.svgTag.AddAttrib("xmlns:inkscape", []byte(inkscapeNamespace))
+	}
+	if pi.stretch {
+		pi.svgTag.AddAttrib("preserveAspectRatio", []byte("none"))
+	}
+	if pi.crispEdges {
+		pi.svgTag.AddAttrib("shape-rendering", []byte("crispEdges"))
+	}
+	if pi.metadata.accessible() {
+		pi.svgTag.AddAttrib("role", []byte("img"))
+		pi.svgTag.AddAttrib("aria-labelledby", pi.metadata.labelledBy())
+	}
+}
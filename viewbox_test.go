@@ -0,0 +1,110 @@
+package png2svg
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// rootAttributes returns the attributes of the <svg> tag of the given SVG document
+func rootAttributes(t *testing.T, svgDocument []byte) map[string]string {
+	root, err := parseNodes(svgDocument)
+	if err != nil {
+		t.Fatalf("Failed to parse the SVG document: %v", err)
+	}
+	attrs := make(map[string]string)
+	for _, attr := range root.attrs {
+		attrs[qualifiedName(attr.Name)] = attr.Value
+	}
+	return attrs
+}
+
+func TestDisplaySize(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 8))
+	for _, tc := range []struct {
+		name          string
+		set           func(pi *PixelImage) error
+		width, height string
+		stretch       bool
+	}{
+		{"scale", func(pi *PixelImage) error { return pi.SetScale(2.5) }, "40px", "20px", false},
+		{"width", func(pi *PixelImage) error { return pi.SetDisplaySize(64, 0) }, "64px", "32px", false},
+		{"height", func(pi *PixelImage) error { return pi.SetDisplaySize(0, 4) }, "8px", "4px", false},
+		{"both", func(pi *PixelImage) error { return pi.SetDisplaySize(100, 10) }, "100px", "10px", false},
+		{"pixel size", func(pi *PixelImage) error { return pi.SetPixelSize(2, 3) }, "32px", "24px", true},
+	} {
+		pi := NewPixelImage(img, false)
+		if err := tc.set(pi); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		attrs := rootAttributes(t, pi.Bytes())
+		if attrs["viewBox"] != "0 0 16 8" || attrs["width"] != tc.width || attrs["height"] != tc.height {
+			t.Errorf("%s: expected viewBox \"0 0 16 8\", width %s and height %s, got %v", tc.name, tc.width, tc.height, attrs)
+		}
+		if _, stretch := attrs["preserveAspectRatio"]; stretch != tc.stretch {
+			t.Errorf("%s: expected preserveAspectRatio to be set: %v, got %v", tc.name, tc.stretch, attrs)
+		}
+	}
+
+	pi := NewPixelImage(img, false)
+	for _, err := range []error{pi.SetScale(0), pi.SetScale(-1), pi.SetDisplaySize(0, 0), pi.SetDisplaySize(-1, 2), pi.SetPixelSize(1, 0)} {
+		if err == nil {
+			t.Error("Expected an error for an invalid size")
+		}
+	}
+}
+
+func TestCrispEdgesAndCompact(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if x < 4 {
+				img.Set(x, y, color.NRGBA{0xff, 0, 0, 0xff})
+			} else {
+				img.Set(x, y, color.NRGBA{0, 0, 0xff, 0xff})
+			}
+		}
+	}
+
+	pi := NewPixelImage(img, false)
+	pi.SetCrispEdges(true)
+	pi.coverBoxes()
+	normal := pi.Bytes()
+	if attrs := rootAttributes(t, normal); attrs["shape-rendering"] != "crispEdges" || attrs["width"] != "8px" {
+		t.Errorf("Expected shape-rendering=\"crispEdges\" and a width of 8px, got %v", attrs)
+	}
+	if !bytes.HasPrefix(normal, []byte(xmlDeclaration)) || !bytes.Contains(normal, []byte("<rect ")) {
+		t.Errorf("Expected an XML declaration and <rect> elements, got %s", normal)
+	}
+
+	pi = NewPixelImage(img, false)
+	pi.SetCompact(true)
+	pi.coverBoxes()
+	compact := pi.Bytes()
+	if attrs := rootAttributes(t, compact); attrs["width"] != "8" || attrs["height"] != "8" {
+		t.Errorf("Expected the width and height to have no units, got %v", attrs)
+	}
+	for _, unexpected := range []string{"<?xml", "<rect", ` x="0"`, ` y="0"`, "shape-rendering"} {
+		if bytes.Contains(compact, []byte(unexpected)) {
+			t.Errorf("Expected the compact SVG document to not contain %s: %s", unexpected, compact)
+		}
+	}
+	for _, expected := range []string{`<path fill="red" d="M0 0h4v8h-4z"/>`, `<path fill="#00f" d="M4 0h4v8h-4z"/>`} {
+		if !bytes.Contains(compact, []byte(expected)) {
+			t.Errorf("Expected the compact SVG document to contain %s: %s", expected, compact)
+		}
+	}
+	if len(compact) >= len(normal) {
+		t.Errorf("Expected the compact SVG document to be smaller, got %d and %d bytes", len(compact), len(normal))
+	}
+
+	// SetEncoding can be used after SetCompact to keep the <rect> elements
+	pi = NewPixelImage(img, false)
+	pi.SetCompact(true)
+	pi.SetEncoding(RectEncoding)
+	pi.coverBoxes()
+	if svgDocument := pi.Bytes(); !bytes.Contains(svgDocument, []byte("<rect ")) || bytes.Contains(svgDocument, []byte("<path ")) {
+		t.Errorf("Expected <rect> elements, got %s", svgDocument)
+	}
+}